@@ -0,0 +1,82 @@
+package apikey
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"github.com/zchelalo/rest-api-go/pkg/response"
+	"github.com/zchelalo/rest-api-go/pkg/validator"
+)
+
+type (
+	Controller func(w http.ResponseWriter, req *http.Request)
+
+	Endpoints struct {
+		Create Controller
+		GetAll Controller
+		Revoke Controller
+	}
+
+	CreateRequest struct {
+		Name   string   `json:"name" validate:"required,max=100"`
+		Scopes []string `json:"scopes" validate:"min=1"`
+	}
+)
+
+func MakeEndpoints(service Service) Endpoints {
+	return Endpoints{
+		Create: makeCreateEndpoint(service),
+		GetAll: makeGetAllEndpoint(service),
+		Revoke: makeRevokeEndpoint(service),
+	}
+}
+
+func makeCreateEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var request CreateRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			response.Error(w, apperr.BadRequest("invalid_request", fmt.Sprintf("Invalid request format, %v", err.Error())))
+			return
+		}
+
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		key, err := service.Create(req.Context(), request.Name, request.Scopes)
+		if err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		response.Created(w, key)
+	}
+}
+
+func makeGetAllEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		keys, err := service.GetAll(req.Context())
+		if err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		response.OK(w, keys, nil)
+	}
+}
+
+func makeRevokeEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id := req.PathValue("id")
+
+		if err := service.Revoke(req.Context(), id); err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		response.OK(w, "API key revoked successfully", nil)
+	}
+}
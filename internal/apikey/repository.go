@@ -0,0 +1,94 @@
+package apikey
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/zchelalo/rest-api-go/internal/domain"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"gorm.io/gorm"
+)
+
+// lastUsedResolution limits how often using a key writes its last used time.
+const lastUsedResolution = time.Minute
+
+type (
+	Repository interface {
+		Create(ctx context.Context, key *domain.ApiKey) error
+		GetAll(ctx context.Context) ([]domain.ApiKey, error)
+		GetActiveByHash(ctx context.Context, keyHash string) (*domain.ApiKey, error)
+		Revoke(ctx context.Context, id string) error
+		TouchLastUsed(ctx context.Context, id string, now time.Time) error
+	}
+
+	repository struct {
+		log *slog.Logger
+		db  *gorm.DB
+	}
+)
+
+func NewRepository(log *slog.Logger, db *gorm.DB) Repository {
+	return &repository{
+		log: log,
+		db:  db,
+	}
+}
+
+func (repo *repository) Create(ctx context.Context, key *domain.ApiKey) error {
+	if err := repo.db.WithContext(ctx).Create(key).Error; err != nil {
+		repo.log.ErrorContext(ctx, "create api key", "error", err, "id", key.Id)
+		return apperr.FromDB(err, "api_key")
+	}
+
+	repo.log.InfoContext(ctx, "api key created", "id", key.Id)
+	return nil
+}
+
+func (repo *repository) GetAll(ctx context.Context) ([]domain.ApiKey, error) {
+	var keys []domain.ApiKey
+
+	if err := repo.db.WithContext(ctx).Order("created_at desc").Find(&keys).Error; err != nil {
+		repo.log.ErrorContext(ctx, "get all api keys", "error", err)
+		return nil, apperr.FromDB(err, "api_key")
+	}
+
+	return keys, nil
+}
+
+func (repo *repository) GetActiveByHash(ctx context.Context, keyHash string) (*domain.ApiKey, error) {
+	var key domain.ApiKey
+
+	if err := repo.db.WithContext(ctx).Where("key_hash = ? AND revoked_at IS NULL", keyHash).First(&key).Error; err != nil {
+		repo.log.DebugContext(ctx, "get api key", "error", err)
+		return nil, apperr.FromDB(err, "api_key")
+	}
+
+	return &key, nil
+}
+
+func (repo *repository) Revoke(ctx context.Context, id string) error {
+	result := repo.db.WithContext(ctx).Model(&domain.ApiKey{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", time.Now())
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "revoke api key", "error", result.Error, "id", id)
+		return apperr.FromDB(result.Error, "api_key")
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.FromDB(gorm.ErrRecordNotFound, "api_key")
+	}
+
+	repo.log.InfoContext(ctx, "api key revoked", "id", id)
+	return nil
+}
+
+func (repo *repository) TouchLastUsed(ctx context.Context, id string, now time.Time) error {
+	tx := repo.db.WithContext(ctx).Model(&domain.ApiKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-lastUsedResolution))
+	if err := tx.Update("last_used_at", now).Error; err != nil {
+		repo.log.ErrorContext(ctx, "update api key last used", "error", err, "id", id)
+		return apperr.FromDB(err, "api_key")
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package apikey
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/zchelalo/rest-api-go/internal/domain"
+	"github.com/zchelalo/rest-api-go/internal/policy"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"github.com/zchelalo/rest-api-go/pkg/middleware"
+	"github.com/zchelalo/rest-api-go/pkg/token"
+)
+
+// keyPrefix makes keys easy to recognise, e.g. by secret scanners.
+const keyPrefix = "rak_"
+
+var ErrInvalidAPIKey = apperr.Unauthorized("invalid_api_key", "api key is invalid or revoked")
+
+type (
+	// CreatedKey is only returned when the key is created, it's the one time
+	// the plain key is available.
+	CreatedKey struct {
+		*domain.ApiKey
+		Key string `json:"key"`
+	}
+
+	Service interface {
+		Create(ctx context.Context, name string, scopes []string) (*CreatedKey, error)
+		GetAll(ctx context.Context) ([]domain.ApiKey, error)
+		Revoke(ctx context.Context, id string) error
+		VerifyAPIKey(ctx context.Context, key string) (*middleware.APIKey, error)
+	}
+
+	service struct {
+		log        *slog.Logger
+		repository Repository
+	}
+)
+
+func NewService(log *slog.Logger, repo Repository) Service {
+	return &service{
+		log:        log,
+		repository: repo,
+	}
+}
+
+func (srv *service) Create(ctx context.Context, name string, scopes []string) (*CreatedKey, error) {
+	srv.log.DebugContext(ctx, "create api key")
+	actor := policy.FromContext(ctx)
+	if err := policy.CanManageAPIKeys(actor); err != nil {
+		return nil, err
+	}
+
+	var validation apperr.FieldErrors
+	keyScopes := make([]domain.Scope, 0, len(scopes))
+	for _, scope := range scopes {
+		if !domain.Scope(scope).IsValid() {
+			validation.Add("scopes", "invalid", fmt.Sprintf("%s is not a valid scope", scope))
+			continue
+		}
+		keyScopes = append(keyScopes, domain.Scope(scope))
+	}
+	if err := validation.Err(); err != nil {
+		return nil, err
+	}
+
+	secret, err := token.Opaque()
+	if err != nil {
+		return nil, apperr.Internal(err)
+	}
+	raw := keyPrefix + secret
+
+	key := &domain.ApiKey{
+		Name:      name,
+		Prefix:    raw[:len(keyPrefix)+8],
+		KeyHash:   token.Hash(raw),
+		Scopes:    keyScopes,
+		CreatedBy: &actor.UserId,
+	}
+	if err := srv.repository.Create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return &CreatedKey{ApiKey: key, Key: raw}, nil
+}
+
+func (srv *service) GetAll(ctx context.Context) ([]domain.ApiKey, error) {
+	srv.log.DebugContext(ctx, "get all api keys")
+	if err := policy.CanManageAPIKeys(policy.FromContext(ctx)); err != nil {
+		return nil, err
+	}
+	return srv.repository.GetAll(ctx)
+}
+
+func (srv *service) Revoke(ctx context.Context, id string) error {
+	srv.log.DebugContext(ctx, "revoke api key")
+	if err := policy.CanManageAPIKeys(policy.FromContext(ctx)); err != nil {
+		return err
+	}
+	return srv.repository.Revoke(ctx, id)
+}
+
+// VerifyAPIKey resolves key for the authentication middleware. Keys act as
+// admins within their scopes, short of what the policies keep to admin users.
+func (srv *service) VerifyAPIKey(ctx context.Context, key string) (*middleware.APIKey, error) {
+	stored, err := srv.repository.GetActiveByHash(ctx, token.Hash(key))
+	if err != nil {
+		if apperr.From(err).Kind == apperr.KindNotFound {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	// failing to record the usage shouldn't reject the request
+	if err := srv.repository.TouchLastUsed(ctx, stored.Id, time.Now()); err != nil {
+		srv.log.WarnContext(ctx, "recording api key usage", "error", err, "id", stored.Id)
+	}
+
+	scopes := make([]string, 0, len(stored.Scopes))
+	for _, scope := range stored.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+
+	return &middleware.APIKey{
+		Id:     stored.Id,
+		Role:   string(domain.RoleAdmin),
+		Scopes: scopes,
+	}, nil
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"github.com/zchelalo/rest-api-go/pkg/response"
+	"github.com/zchelalo/rest-api-go/pkg/validator"
+)
+
+type (
+	Controller func(w http.ResponseWriter, req *http.Request)
+
+	Endpoints struct {
+		Login          Controller
+		Refresh        Controller
+		Logout         Controller
+		ForgotPassword Controller
+		ResetPassword  Controller
+	}
+
+	LoginRequest struct {
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	RefreshRequest struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
+
+	ForgotPasswordRequest struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	ResetPasswordRequest struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required"`
+	}
+)
+
+func MakeEndpoints(service Service) Endpoints {
+	return Endpoints{
+		Login:          makeLoginEndpoint(service),
+		Refresh:        makeRefreshEndpoint(service),
+		Logout:         makeLogoutEndpoint(service),
+		ForgotPassword: makeForgotPasswordEndpoint(service),
+		ResetPassword:  makeResetPasswordEndpoint(service),
+	}
+}
+
+func makeLoginEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var request LoginRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			response.Error(w, apperr.BadRequest("invalid_request", fmt.Sprintf("Invalid request format, %v", err.Error())))
+			return
+		}
+
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		tokens, err := service.Login(req.Context(), request.Email, request.Password)
+		if err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		response.OK(w, tokens, nil)
+	}
+}
+
+func makeRefreshEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var request RefreshRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			response.Error(w, apperr.BadRequest("invalid_request", fmt.Sprintf("Invalid request format, %v", err.Error())))
+			return
+		}
+
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		tokens, err := service.Refresh(req.Context(), request.RefreshToken)
+		if err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		response.OK(w, tokens, nil)
+	}
+}
+
+func makeLogoutEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var request RefreshRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			response.Error(w, apperr.BadRequest("invalid_request", fmt.Sprintf("Invalid request format, %v", err.Error())))
+			return
+		}
+
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		if err := service.Logout(req.Context(), request.RefreshToken); err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		response.OK(w, "Logged out successfully", nil)
+	}
+}
+
+func makeForgotPasswordEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var request ForgotPasswordRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			response.Error(w, apperr.BadRequest("invalid_request", fmt.Sprintf("Invalid request format, %v", err.Error())))
+			return
+		}
+
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		service.ForgotPassword(req.Context(), request.Email)
+
+		response.Accepted(w, "If the email belongs to an account, a reset token has been sent to it")
+	}
+}
+
+func makeResetPasswordEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var request ResetPasswordRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			response.Error(w, apperr.BadRequest("invalid_request", fmt.Sprintf("Invalid request format, %v", err.Error())))
+			return
+		}
+
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		if err := service.ResetPassword(req.Context(), request.Token, request.Password); err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		response.OK(w, "Password reset successfully", nil)
+	}
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/zchelalo/rest-api-go/internal/domain"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"github.com/zchelalo/rest-api-go/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	Repository interface {
+		Create(ctx context.Context, token *domain.RefreshToken) error
+		GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error)
+		Revoke(ctx context.Context, id string) error
+		RevokeAll(ctx context.Context, userId string) error
+		Transaction(ctx context.Context, fn func(repo Repository) error) error
+		WithTx(ctx context.Context) context.Context
+	}
+
+	repository struct {
+		log *slog.Logger
+		db  *gorm.DB
+	}
+)
+
+func NewRepository(log *slog.Logger, db *gorm.DB) Repository {
+	return &repository{
+		log: log,
+		db:  db,
+	}
+}
+
+func (repo *repository) Create(ctx context.Context, token *domain.RefreshToken) error {
+	if err := repo.db.WithContext(ctx).Create(token).Error; err != nil {
+		repo.log.ErrorContext(ctx, "create refresh token", "error", err, "user_id", token.UserId)
+		return apperr.FromDB(err, "refresh_token")
+	}
+
+	return nil
+}
+
+// GetByHash locks the row so concurrent refreshes of the same token can't both
+// succeed.
+func (repo *repository) GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	var token domain.RefreshToken
+
+	if err := repo.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		repo.log.DebugContext(ctx, "get refresh token", "error", err)
+		return nil, apperr.FromDB(err, "refresh_token")
+	}
+
+	return &token, nil
+}
+
+func (repo *repository) Revoke(ctx context.Context, id string) error {
+	tx := repo.db.WithContext(ctx).Model(&domain.RefreshToken{}).Where("id = ? AND revoked_at IS NULL", id)
+	if err := tx.Update("revoked_at", time.Now()).Error; err != nil {
+		repo.log.ErrorContext(ctx, "revoke refresh token", "error", err, "id", id)
+		return apperr.FromDB(err, "refresh_token")
+	}
+
+	return nil
+}
+
+func (repo *repository) RevokeAll(ctx context.Context, userId string) error {
+	tx := repo.db.WithContext(ctx).Model(&domain.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", userId)
+	if err := tx.Update("revoked_at", time.Now()).Error; err != nil {
+		repo.log.ErrorContext(ctx, "revoke user refresh tokens", "error", err, "user_id", userId)
+		return apperr.FromDB(err, "refresh_token")
+	}
+
+	return nil
+}
+
+// Transaction joins the transaction carried by ctx, if any.
+func (repo *repository) Transaction(ctx context.Context, fn func(repo Repository) error) error {
+	return database.Conn(ctx, repo.db).Transaction(func(tx *gorm.DB) error {
+		return fn(&repository{
+			log: repo.log,
+			db:  tx,
+		})
+	})
+}
+
+// WithTx returns ctx carrying the transaction the repository runs in, so
+// repositories of other packages handed ctx join it.
+func (repo *repository) WithTx(ctx context.Context) context.Context {
+	return database.WithTx(ctx, repo.db)
+}
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/zchelalo/rest-api-go/internal/domain"
+	"github.com/zchelalo/rest-api-go/internal/user"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"github.com/zchelalo/rest-api-go/pkg/background"
+	"github.com/zchelalo/rest-api-go/pkg/token"
+)
+
+// dummyPasswordHash is compared against when the email doesn't exist so a
+// failed login takes the same time whether or not the account exists.
+const dummyPasswordHash = "$2a$10$5ce54y02p0CRpX7jAyx7vuo1LadpxnROR4IXwnGmuMsOLkJeP2Lty"
+
+var (
+	ErrInvalidCredentials  = apperr.Unauthorized("invalid_credentials", "email or password is incorrect")
+	ErrInvalidRefreshToken = apperr.Unauthorized("invalid_refresh_token", "refresh token is invalid, expired or revoked")
+)
+
+type (
+	Tokens struct {
+		AccessToken           string    `json:"access_token"`
+		TokenType             string    `json:"token_type"`
+		ExpiresIn             int       `json:"expires_in"`
+		RefreshToken          string    `json:"refresh_token"`
+		RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+	}
+
+	Service interface {
+		Login(ctx context.Context, email, password string) (*Tokens, error)
+		Refresh(ctx context.Context, refreshToken string) (*Tokens, error)
+		Logout(ctx context.Context, refreshToken string) error
+		ForgotPassword(ctx context.Context, email string)
+		ResetPassword(ctx context.Context, resetToken, password string) error
+	}
+
+	service struct {
+		log             *slog.Logger
+		repository      Repository
+		userService     user.Service
+		tokens          *token.Manager
+		background      *background.Runner
+		refreshTokenTTL time.Duration
+	}
+)
+
+func NewService(log *slog.Logger, repo Repository, userService user.Service, tokens *token.Manager, runner *background.Runner, refreshTokenTTL time.Duration) Service {
+	return &service{
+		log:             log,
+		repository:      repo,
+		userService:     userService,
+		tokens:          tokens,
+		background:      runner,
+		refreshTokenTTL: refreshTokenTTL,
+	}
+}
+
+func (srv *service) Login(ctx context.Context, email, password string) (*Tokens, error) {
+	srv.log.DebugContext(ctx, "login")
+
+	user, err := srv.userService.GetByEmail(ctx, email)
+	if err != nil {
+		if apperr.From(err).Kind != apperr.KindNotFound {
+			return nil, err
+		}
+		user = &domain.User{PasswordHash: dummyPasswordHash}
+	}
+
+	ok, err := user.CheckPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || user.Id == "" {
+		srv.log.InfoContext(ctx, "login failed")
+		return nil, ErrInvalidCredentials
+	}
+
+	return srv.issue(ctx, srv.repository, user)
+}
+
+// Refresh rotates refreshToken: it is revoked and a new token pair returned.
+// Presenting an already revoked token revokes every token of its user, since
+// it means the token has leaked.
+func (srv *service) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	srv.log.DebugContext(ctx, "refresh token")
+
+	var tokens *Tokens
+	var reused bool
+	err := srv.repository.Transaction(ctx, func(repo Repository) error {
+		stored, err := repo.GetByHash(ctx, token.Hash(refreshToken))
+		if err != nil {
+			if apperr.From(err).Kind == apperr.KindNotFound {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if stored.RevokedAt != nil {
+			reused = true
+			return repo.RevokeAll(ctx, stored.UserId)
+		}
+
+		if !stored.Active(time.Now()) {
+			return ErrInvalidRefreshToken
+		}
+
+		owner, err := srv.userService.Lookup(ctx, stored.UserId)
+		if err != nil {
+			if apperr.From(err).Kind == apperr.KindNotFound {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if err := repo.Revoke(ctx, stored.Id); err != nil {
+			return err
+		}
+
+		tokens, err = srv.issue(ctx, repo, owner)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reused {
+		srv.log.WarnContext(ctx, "revoked refresh token reused, revoking all sessions")
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return tokens, nil
+}
+
+// Logout revokes refreshToken. Unknown or already revoked tokens are ignored.
+func (srv *service) Logout(ctx context.Context, refreshToken string) error {
+	srv.log.DebugContext(ctx, "logout")
+
+	return srv.repository.Transaction(ctx, func(repo Repository) error {
+		stored, err := repo.GetByHash(ctx, token.Hash(refreshToken))
+		if err != nil {
+			if apperr.From(err).Kind == apperr.KindNotFound {
+				return nil
+			}
+			return err
+		}
+
+		return repo.Revoke(ctx, stored.Id)
+	})
+}
+
+// ForgotPassword mails a reset token in the background so the response, and
+// how long it takes, is the same whether or not the email belongs to a user.
+func (srv *service) ForgotPassword(ctx context.Context, email string) {
+	srv.log.DebugContext(ctx, "forgot password")
+
+	srv.background.Go(ctx, "request password reset", func(ctx context.Context) error {
+		return srv.userService.RequestPasswordReset(ctx, email)
+	})
+}
+
+// ResetPassword also revokes every refresh token of the user, signing out
+// sessions that may have been opened with the old password. Both happen in one
+// transaction, so the password never changes while the old sessions survive.
+func (srv *service) ResetPassword(ctx context.Context, resetToken, password string) error {
+	srv.log.DebugContext(ctx, "reset password")
+
+	var userId string
+	err := srv.repository.Transaction(ctx, func(repo Repository) error {
+		var err error
+		userId, err = srv.userService.ResetPassword(repo.WithTx(ctx), resetToken, password)
+		if err != nil {
+			return err
+		}
+		return repo.RevokeAll(ctx, userId)
+	})
+	if err != nil {
+		return err
+	}
+
+	srv.log.InfoContext(ctx, "password reset", "user_id", userId)
+	return nil
+}
+
+func (srv *service) issue(ctx context.Context, repo Repository, user *domain.User) (*Tokens, error) {
+	accessToken, _, err := srv.tokens.Issue(user.Id, string(user.Role))
+	if err != nil {
+		return nil, apperr.Internal(err)
+	}
+
+	refreshToken, err := token.Opaque()
+	if err != nil {
+		return nil, apperr.Internal(err)
+	}
+
+	stored := &domain.RefreshToken{
+		UserId:    user.Id,
+		TokenHash: token.Hash(refreshToken),
+		ExpiresAt: time.Now().Add(srv.refreshTokenTTL),
+	}
+	if err := repo.Create(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		AccessToken:           accessToken,
+		TokenType:             "Bearer",
+		ExpiresIn:             int(srv.tokens.TTL().Seconds()),
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: stored.ExpiresAt,
+	}, nil
+}
@@ -6,54 +6,60 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/zchelalo/rest-api-go/internal/domain"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
 	"github.com/zchelalo/rest-api-go/pkg/meta"
-)
-
-type status string
-
-const (
-	statusSuccess status = "success"
-	statusError   status = "error"
+	"github.com/zchelalo/rest-api-go/pkg/response"
+	"github.com/zchelalo/rest-api-go/pkg/validator"
 )
 
 type (
 	Controller func(w http.ResponseWriter, req *http.Request)
 
+	Config struct {
+		LimPageDef int
+	}
+
 	Endpoints struct {
-		Create Controller
-		GetAll Controller
-		Get    Controller
-		Update Controller
-		Delete Controller
+		Create            Controller
+		GetAll            Controller
+		Get               Controller
+		Update            Controller
+		Delete            Controller
+		Restore           Controller
+		DeletePermanently Controller
 	}
 
 	CreateRequest struct {
-		Name      string `json:"name"`
-		StartDate string `json:"start_date"`
-		EndDate   string `json:"end_date"`
+		Name               string  `json:"name" validate:"required,max=50"`
+		StartDate          string  `json:"start_date" validate:"required,date"`
+		EndDate            string  `json:"end_date" validate:"required,date"`
+		Capacity           int     `json:"capacity" validate:"min=0"`
+		EnrollmentOpensAt  *string `json:"enrollment_opens_at" validate:"datetime"`
+		EnrollmentClosesAt *string `json:"enrollment_closes_at" validate:"datetime"`
+		InstructorId       *string `json:"instructor_id" validate:"required,uuid"`
 	}
 
 	UpdateRequest struct {
-		Name      *string `json:"name"`
-		StartDate *string `json:"start_date"`
-		EndDate   *string `json:"end_date"`
-	}
-
-	Response struct {
-		Status status      `json:"status"`
-		Data   interface{} `json:"data,omitempty"`
-		Error  string      `json:"error,omitempty"`
-		Meta   *meta.Meta  `json:"meta,omitempty"`
+		Name               *string `json:"name" validate:"required,max=50"`
+		StartDate          *string `json:"start_date" validate:"required,date"`
+		EndDate            *string `json:"end_date" validate:"required,date"`
+		Capacity           *int    `json:"capacity" validate:"min=0"`
+		EnrollmentOpensAt  *string `json:"enrollment_opens_at" validate:"datetime"`
+		EnrollmentClosesAt *string `json:"enrollment_closes_at" validate:"datetime"`
+		InstructorId       *string `json:"instructor_id" validate:"required,uuid"`
 	}
 )
 
-func MakeEndpoints(service Service) Endpoints {
+func MakeEndpoints(service Service, config Config) Endpoints {
 	return Endpoints{
-		Create: makeCreateEndpoint(service),
-		GetAll: makeGetAllEndpoint(service),
-		Get:    makeGetEndpoint(service),
-		Update: makeUpdateEndpoint(service),
-		Delete: makeDeleteEndpoint(service),
+		Create:            makeCreateEndpoint(service),
+		GetAll:            makeGetAllEndpoint(service, config),
+		Get:               makeGetEndpoint(service),
+		Update:            makeUpdateEndpoint(service),
+		Delete:            makeDeleteEndpoint(service),
+		Restore:           makeRestoreEndpoint(service),
+		DeletePermanently: makeDeletePermanentlyEndpoint(service),
 	}
 }
 
@@ -61,105 +67,50 @@ func makeCreateEndpoint(service Service) Controller {
 	return func(w http.ResponseWriter, req *http.Request) {
 		var request CreateRequest
 		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(Response{
-				Status: statusError,
-				Error:  fmt.Sprintf("Invalid request format, %v", err.Error()),
-			})
-			return
-		}
-
-		if request.Name == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(Response{
-				Status: statusError,
-				Error:  "Name is required",
-			})
+			response.Error(w, apperr.BadRequest("invalid_request", fmt.Sprintf("Invalid request format, %v", err.Error())))
 			return
 		}
 
-		if request.StartDate == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(Response{
-				Status: statusError,
-				Error:  "Start date is required",
-			})
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, err)
 			return
 		}
 
-		if request.EndDate == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(Response{
-				Status: statusError,
-				Error:  "End date is required",
-			})
-			return
-		}
-
-		course, err := service.Create(request.Name, request.StartDate, request.EndDate)
+		course, err := service.Create(req.Context(), request.Name, request.StartDate, request.EndDate, request.Capacity, request.EnrollmentOpensAt, request.EnrollmentClosesAt, request.InstructorId)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(Response{
-				Status: statusError,
-				Error:  err.Error(),
-			})
+			response.Error(w, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(Response{
-			Status: statusSuccess,
-			Data:   course,
-		})
+		response.Created(w, course)
 	}
 }
 
-func makeGetAllEndpoint(service Service) Controller {
+func makeGetAllEndpoint(service Service, config Config) Controller {
 	return func(w http.ResponseWriter, req *http.Request) {
 		queries := req.URL.Query()
 		filters := Filters{
-			Name: queries.Get("name"),
+			Name:    queries.Get("name"),
+			Deleted: domain.DeletedFilter(queries.Get("deleted")),
 		}
 
 		limit, _ := strconv.Atoi(queries.Get("limit"))
 		page, _ := strconv.Atoi(queries.Get("page"))
 
-		count, err := service.Count(filters)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  err.Error(),
-			})
-			return
-		}
-		meta, err := meta.New(page, limit, count)
+		count, err := service.Count(req.Context(), filters)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  err.Error(),
-			})
+			response.Error(w, err)
 			return
 		}
+		meta := meta.New(page, limit, count, config.LimPageDef)
 
-		courses, err := service.GetAll(filters, meta.Offset(), meta.Limit())
+		courses, err := service.GetAll(req.Context(), filters, meta.Offset(), meta.Limit())
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  err.Error(),
-			})
+			response.Error(w, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-
-		json.NewEncoder(w).Encode(&Response{
-			Status: statusSuccess,
-			Data:   courses,
-			Meta:   meta,
-		})
+		response.OK(w, courses, meta)
 	}
 }
 
@@ -167,22 +118,13 @@ func makeGetEndpoint(service Service) Controller {
 	return func(w http.ResponseWriter, req *http.Request) {
 		id := req.PathValue("id")
 
-		course, err := service.Get(id)
+		course, err := service.Get(req.Context(), id)
 		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  err.Error(),
-			})
+			response.Error(w, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-
-		json.NewEncoder(w).Encode(&Response{
-			Status: statusSuccess,
-			Data:   course,
-		})
+		response.OK(w, course, nil)
 	}
 }
 
@@ -192,78 +134,64 @@ func makeUpdateEndpoint(service Service) Controller {
 
 		var request UpdateRequest
 		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  fmt.Sprintf("Invalid request format, %v", err.Error()),
-			})
+			response.Error(w, apperr.BadRequest("invalid_request", fmt.Sprintf("Invalid request format, %v", err.Error())))
 			return
 		}
 
-		if request.Name != nil && *request.Name == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  "Name is required",
-			})
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, err)
 			return
 		}
 
-		if request.StartDate != nil && *request.StartDate == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  "Start date is required",
-			})
+		course, err := service.Update(req.Context(), id, request.Name, request.StartDate, request.EndDate, request.Capacity, request.EnrollmentOpensAt, request.EnrollmentClosesAt, request.InstructorId)
+		if err != nil {
+			response.Error(w, err)
 			return
 		}
 
-		if request.EndDate != nil && *request.EndDate == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  "End date is required",
-			})
-			return
-		}
+		response.OK(w, course, nil)
+	}
+}
+
+func makeDeleteEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id := req.PathValue("id")
+
+		force, _ := strconv.ParseBool(req.URL.Query().Get("force"))
 
-		if err := service.Update(id, request.Name, request.StartDate, request.EndDate); err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  "Course doesn't exist",
-			})
+		err := service.Delete(req.Context(), id, force)
+		if err != nil {
+			response.Error(w, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-
-		json.NewEncoder(w).Encode(&Response{
-			Status: statusSuccess,
-			Data:   "Course updated successfully",
-		})
+		response.OK(w, "Course deleted successfully", nil)
 	}
 }
 
-func makeDeleteEndpoint(service Service) Controller {
+func makeRestoreEndpoint(service Service) Controller {
 	return func(w http.ResponseWriter, req *http.Request) {
 		id := req.PathValue("id")
 
-		err := service.Delete(id)
+		course, err := service.Restore(req.Context(), id)
 		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  err.Error(),
-			})
+			response.Error(w, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		response.OK(w, course, nil)
+	}
+}
+
+func makeDeletePermanentlyEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id := req.PathValue("id")
+
+		if err := service.DeletePermanently(req.Context(), id); err != nil {
+			response.Error(w, err)
+			return
+		}
 
-		json.NewEncoder(w).Encode(&Response{
-			Status: statusSuccess,
-			Data:   "Course deleted successfully",
-		})
+		response.OK(w, "Course deleted permanently", nil)
 	}
 }
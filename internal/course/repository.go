@@ -1,75 +1,85 @@
 package course
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/zchelalo/rest-api-go/internal/domain"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"github.com/zchelalo/rest-api-go/pkg/database"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type (
 	Repository interface {
-		Create(course *domain.Course) error
-		GetAll(filters Filters, offset, limit int) ([]domain.Course, error)
-		Get(id string) (*domain.Course, error)
-		Update(id string, name *string, startDate, endDate *time.Time) error
-		Delete(id string) error
-		Count(filters Filters) (int, error)
+		Create(ctx context.Context, course *domain.Course) error
+		GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Course, error)
+		Get(ctx context.Context, id string) (*domain.Course, error)
+		Update(ctx context.Context, id string, name *string, startDate, endDate *time.Time, capacity *int, enrollmentOpensAt, enrollmentClosesAt *time.Time, instructorId *string) error
+		Delete(ctx context.Context, id string) error
+		Lock(ctx context.Context, id string) error
+		CountOpenEnrollments(ctx context.Context, id string) (*EnrollmentCounts, error)
+		Transaction(ctx context.Context, fn func(repo Repository) error) error
+		WithTx(ctx context.Context) context.Context
+		Restore(ctx context.Context, id string) error
+		DeletePermanently(ctx context.Context, id string) error
+		Count(ctx context.Context, filters Filters) (int, error)
 	}
 
 	repository struct {
 		db  *gorm.DB
-		log *log.Logger
+		log *slog.Logger
 	}
 )
 
-func NewRepository(log *log.Logger, db *gorm.DB) Repository {
+func NewRepository(log *slog.Logger, db *gorm.DB) Repository {
 	return &repository{
 		db:  db,
 		log: log,
 	}
 }
 
-func (repo *repository) Create(course *domain.Course) error {
-	if err := repo.db.Create(course).Error; err != nil {
-		repo.log.Println(err)
-		return err
+func (repo *repository) Create(ctx context.Context, course *domain.Course) error {
+	if err := repo.db.WithContext(ctx).Create(course).Error; err != nil {
+		repo.log.ErrorContext(ctx, "create course", "error", err, "id", course.Id)
+		return apperr.FromDB(err, "course")
 	}
 
-	repo.log.Println("course created with id: ", course.Id)
+	repo.log.InfoContext(ctx, "course created", "id", course.Id)
 	return nil
 }
 
-func (repo *repository) GetAll(filters Filters, offset, limit int) ([]domain.Course, error) {
+func (repo *repository) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Course, error) {
 	var courses []domain.Course
-	tx := repo.db.Model(&courses)
+	tx := repo.db.WithContext(ctx).Model(&courses)
 	tx = applyFilters(tx, filters)
 	tx = tx.Limit(limit).Offset(offset)
 	if err := tx.Order("created_at desc").Find(&courses).Error; err != nil {
-		repo.log.Println(err)
-		return nil, err
+		repo.log.ErrorContext(ctx, "get all courses", "error", err)
+		return nil, apperr.FromDB(err, "course")
 	}
 
 	return courses, nil
 }
 
-func (repo *repository) Get(id string) (*domain.Course, error) {
+func (repo *repository) Get(ctx context.Context, id string) (*domain.Course, error) {
 	course := domain.Course{
 		Id: id,
 	}
 
-	if err := repo.db.Model(&course).First(&course).Error; err != nil {
-		repo.log.Println(err)
-		return nil, err
+	if err := repo.db.WithContext(ctx).Model(&course).First(&course).Error; err != nil {
+		repo.log.ErrorContext(ctx, "get course", "error", err, "id", id)
+		return nil, apperr.FromDB(err, "course")
 	}
 
 	return &course, nil
 }
 
-func (repo *repository) Update(id string, name *string, startDate, endDate *time.Time) error {
+func (repo *repository) Update(ctx context.Context, id string, name *string, startDate, endDate *time.Time, capacity *int, enrollmentOpensAt, enrollmentClosesAt *time.Time, instructorId *string) error {
 	values := make(map[string]interface{})
 
 	if name != nil {
@@ -84,39 +94,170 @@ func (repo *repository) Update(id string, name *string, startDate, endDate *time
 		values["end_date"] = *endDate
 	}
 
-	if err := repo.db.Model(&domain.Course{}).Where("id = ?", id).Updates(values).Error; err != nil {
-		repo.log.Println(err)
+	if capacity != nil {
+		values["capacity"] = *capacity
+	}
+
+	if enrollmentOpensAt != nil {
+		values["enrollment_opens_at"] = *enrollmentOpensAt
+	}
+
+	if enrollmentClosesAt != nil {
+		values["enrollment_closes_at"] = *enrollmentClosesAt
+	}
+
+	if instructorId != nil {
+		values["instructor_id"] = *instructorId
+	}
+
+	if len(values) == 0 {
+		_, err := repo.Get(ctx, id)
 		return err
 	}
 
+	result := repo.db.WithContext(ctx).Model(&domain.Course{}).Where("id = ?", id).Updates(values)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "update course", "error", result.Error, "id", id)
+		return apperr.FromDB(result.Error, "course")
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.FromDB(gorm.ErrRecordNotFound, "course")
+	}
+
 	return nil
 }
 
-func (repo *repository) Delete(id string) error {
+func (repo *repository) Delete(ctx context.Context, id string) error {
 	course := domain.Course{
 		Id: id,
 	}
 
-	if err := repo.db.Model(&course).Delete(&course).Error; err != nil {
-		repo.log.Println(err)
-		return err
+	result := repo.db.WithContext(ctx).Model(&course).Delete(&course)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "delete course", "error", result.Error, "id", id)
+		return apperr.FromDB(result.Error, "course")
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.FromDB(gorm.ErrRecordNotFound, "course")
+	}
+
+	return nil
+}
+
+// Lock takes the same row lock enrolling does, so no enrollment can be added
+// while the course is being deleted.
+func (repo *repository) Lock(ctx context.Context, id string) error {
+	var course domain.Course
+
+	if err := repo.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&course).Error; err != nil {
+		repo.log.ErrorContext(ctx, "lock course", "error", err, "id", id)
+		return apperr.FromDB(err, "course")
 	}
 
 	return nil
 }
 
-func (repo *repository) Count(filters Filters) (int, error) {
+func (repo *repository) CountOpenEnrollments(ctx context.Context, id string) (*EnrollmentCounts, error) {
+	var rows []struct {
+		Status domain.EnrollmentStatus
+		Count  int
+	}
+
+	tx := repo.db.WithContext(ctx).Model(&domain.Enrollment{}).
+		Select("status, COUNT(*) AS count").
+		Where("course_id = ? AND status IN ?", id, domain.OpenEnrollmentStatuses()).
+		Group("status")
+	if err := tx.Scan(&rows).Error; err != nil {
+		repo.log.ErrorContext(ctx, "count course enrollments", "error", err, "id", id)
+		return nil, apperr.FromDB(err, "enrollment")
+	}
+
+	counts := &EnrollmentCounts{}
+	for _, row := range rows {
+		switch row.Status {
+		case domain.EnrollmentWaitlisted:
+			counts.Waitlisted = row.Count
+		case domain.EnrollmentPending:
+			counts.Pending = row.Count
+		case domain.EnrollmentActive:
+			counts.Active = row.Count
+		case domain.EnrollmentStudying:
+			counts.Studying = row.Count
+		}
+		counts.Total += row.Count
+	}
+
+	return counts, nil
+}
+
+// Transaction joins the transaction carried by ctx, if any.
+func (repo *repository) Transaction(ctx context.Context, fn func(repo Repository) error) error {
+	return database.Conn(ctx, repo.db).Transaction(func(tx *gorm.DB) error {
+		return fn(&repository{
+			log: repo.log,
+			db:  tx,
+		})
+	})
+}
+
+// WithTx returns ctx carrying the transaction the repository runs in, so
+// repositories of other packages handed ctx join it.
+func (repo *repository) WithTx(ctx context.Context) context.Context {
+	return database.WithTx(ctx, repo.db)
+}
+
+func (repo *repository) Restore(ctx context.Context, id string) error {
+	result := repo.db.WithContext(ctx).Unscoped().Model(&domain.Course{}).Where("id = ? AND deleted_at IS NOT NULL", id).Update("deleted_at", nil)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "restore course", "error", result.Error, "id", id)
+		return apperr.FromDB(result.Error, "course")
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("deleted_course_not_found", "deleted course not found")
+	}
+
+	repo.log.InfoContext(ctx, "course restored", "id", id)
+	return nil
+}
+
+// DeletePermanently only removes courses that were soft deleted first, so live
+// ones always go through Delete and its rules.
+func (repo *repository) DeletePermanently(ctx context.Context, id string) error {
+	result := repo.db.WithContext(ctx).Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).Delete(&domain.Course{})
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "delete course permanently", "error", result.Error, "id", id)
+		return apperr.FromDB(result.Error, "course")
+	}
+
+	if result.RowsAffected == 0 {
+		if _, err := repo.Get(ctx, id); err == nil {
+			return apperr.Conflict("course_not_deleted", "course has to be deleted before it can be deleted permanently")
+		}
+		return apperr.NotFound("deleted_course_not_found", "deleted course not found")
+	}
+
+	repo.log.InfoContext(ctx, "course deleted permanently", "id", id)
+	return nil
+}
+
+func (repo *repository) Count(ctx context.Context, filters Filters) (int, error) {
 	var count int64
-	tx := repo.db.Model(&domain.Course{})
+	tx := repo.db.WithContext(ctx).Model(&domain.Course{})
 	tx = applyFilters(tx, filters)
 	if err := tx.Count(&count).Error; err != nil {
-		return 0, err
+		repo.log.ErrorContext(ctx, "count courses", "error", err)
+		return 0, apperr.FromDB(err, "course")
 	}
 
 	return int(count), nil
 }
 
 func applyFilters(tx *gorm.DB, filters Filters) *gorm.DB {
+	tx = filters.Deleted.Apply(tx)
+
 	if filters.Name != "" {
 		filters.Name = fmt.Sprintf("%%%s%%", strings.ToLower(filters.Name))
 		tx = tx.Where("lower(name) like ?", filters.Name)
@@ -1,117 +1,295 @@
 package course
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/zchelalo/rest-api-go/internal/domain"
+	"github.com/zchelalo/rest-api-go/internal/policy"
+	"github.com/zchelalo/rest-api-go/internal/user"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
 )
 
+const dateLayout = "2006-01-02"
+
 type (
 	Filters struct {
-		Name string
+		Name    string
+		Deleted domain.DeletedFilter
+	}
+
+	// EnrollmentCounts breaks down the open enrollments that keep a course from
+	// being deleted.
+	EnrollmentCounts struct {
+		Waitlisted int `json:"waitlisted"`
+		Pending    int `json:"pending"`
+		Active     int `json:"active"`
+		Studying   int `json:"studying"`
+		Total      int `json:"total"`
 	}
 
 	Service interface {
-		Create(name, startDate, endDate string) (*domain.Course, error)
-		GetAll(filters Filters, offset, limit int) ([]domain.Course, error)
-		Get(id string) (*domain.Course, error)
-		Update(id string, name, startDate, endDate *string) error
-		Delete(id string) error
-		Count(filters Filters) (int, error)
+		Create(ctx context.Context, name, startDate, endDate string, capacity int, enrollmentOpensAt, enrollmentClosesAt, instructorId *string) (*domain.Course, error)
+		GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Course, error)
+		Get(ctx context.Context, id string) (*domain.Course, error)
+		Update(ctx context.Context, id string, name, startDate, endDate *string, capacity *int, enrollmentOpensAt, enrollmentClosesAt, instructorId *string) (*domain.Course, error)
+		Delete(ctx context.Context, id string, force bool) error
+		Restore(ctx context.Context, id string) (*domain.Course, error)
+		DeletePermanently(ctx context.Context, id string) error
+		Count(ctx context.Context, filters Filters) (int, error)
+	}
+
+	// EnrollmentCanceler cancels the enrollments of a course being deleted,
+	// joining the transaction carried by ctx. The enrollment package provides
+	// it, which can't be imported from here.
+	EnrollmentCanceler interface {
+		CancelForCourse(ctx context.Context, courseId string) error
 	}
 
 	service struct {
-		log        *log.Logger
-		repository Repository
+		log         *slog.Logger
+		repository  Repository
+		userService user.Service
+		enrollments EnrollmentCanceler
 	}
 )
 
-func NewService(repo Repository, log *log.Logger) Service {
+func NewService(repo Repository, log *slog.Logger, userService user.Service, enrollments EnrollmentCanceler) Service {
 	return &service{
-		repository: repo,
-		log:        log,
+		repository:  repo,
+		log:         log,
+		userService: userService,
+		enrollments: enrollments,
 	}
 }
 
-func (srv *service) Create(name, startDate, endDate string) (*domain.Course, error) {
-	startDateParsed, err := time.Parse("2006-01-02", startDate)
-	if err != nil {
-		srv.log.Println(err)
+func (srv *service) Create(ctx context.Context, name, startDate, endDate string, capacity int, enrollmentOpensAt, enrollmentClosesAt, instructorId *string) (*domain.Course, error) {
+	srv.log.DebugContext(ctx, "create course")
+	if err := policy.CanCreateCourse(policy.FromContext(ctx)); err != nil {
 		return nil, err
 	}
 
-	endDateParsed, err := time.Parse("2006-01-02", endDate)
-	if err != nil {
-		srv.log.Println(err)
+	var validation apperr.FieldErrors
+
+	startDateParsed := parseTime(&validation, "start_date", dateLayout, &startDate)
+	endDateParsed := parseTime(&validation, "end_date", dateLayout, &endDate)
+	enrollmentOpensAtParsed := parseTime(&validation, "enrollment_opens_at", time.RFC3339, enrollmentOpensAt)
+	enrollmentClosesAtParsed := parseTime(&validation, "enrollment_closes_at", time.RFC3339, enrollmentClosesAt)
+
+	if err := validation.Err(); err != nil {
+		srv.log.DebugContext(ctx, "invalid course", "error", err)
 		return nil, err
 	}
 
 	course := &domain.Course{
-		Name:      name,
-		StartDate: startDateParsed,
-		EndDate:   endDateParsed,
+		Name:               name,
+		StartDate:          *startDateParsed,
+		EndDate:            *endDateParsed,
+		Capacity:           capacity,
+		EnrollmentOpensAt:  enrollmentOpensAtParsed,
+		EnrollmentClosesAt: enrollmentClosesAtParsed,
+		InstructorId:       instructorId,
+	}
+
+	if err := course.Validate(); err != nil {
+		srv.log.DebugContext(ctx, "invalid course", "error", err)
+		return nil, err
+	}
+
+	if err := srv.checkInstructor(ctx, instructorId); err != nil {
+		return nil, err
 	}
 
-	if err := srv.repository.Create(course); err != nil {
-		srv.log.Println(err)
+	if err := srv.repository.Create(ctx, course); err != nil {
 		return nil, err
 	}
 
 	return course, nil
 }
 
-func (srv *service) GetAll(filters Filters, offset, limit int) ([]domain.Course, error) {
-	srv.log.Println("get all courses service")
-	courses, err := srv.repository.GetAll(filters, offset, limit)
+func (srv *service) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Course, error) {
+	srv.log.DebugContext(ctx, "get all courses")
+	if err := checkFilters(ctx, filters); err != nil {
+		return nil, err
+	}
+	courses, err := srv.repository.GetAll(ctx, filters, offset, limit)
 	if err != nil {
 		return nil, err
 	}
 	return courses, nil
 }
 
-func (srv *service) Get(id string) (*domain.Course, error) {
-	srv.log.Println("get course service")
-	course, err := srv.repository.Get(id)
+func (srv *service) Get(ctx context.Context, id string) (*domain.Course, error) {
+	srv.log.DebugContext(ctx, "get course")
+	course, err := srv.repository.Get(ctx, id)
 	if err != nil {
-		// srv.log.Println(err)
 		return nil, err
 	}
 	return course, nil
 }
 
-func (srv *service) Update(id string, name, startDate, endDate *string) error {
-	srv.log.Println("update course service")
+func (srv *service) Update(ctx context.Context, id string, name, startDate, endDate *string, capacity *int, enrollmentOpensAt, enrollmentClosesAt, instructorId *string) (*domain.Course, error) {
+	srv.log.DebugContext(ctx, "update course")
 
-	var startDateParsed *time.Time
-	if startDate != nil {
-		parsed, err := time.Parse("2006-01-02", *startDate)
-		if err != nil {
-			srv.log.Println(err)
+	var validation apperr.FieldErrors
+
+	startDateParsed := parseTime(&validation, "start_date", dateLayout, startDate)
+	endDateParsed := parseTime(&validation, "end_date", dateLayout, endDate)
+	enrollmentOpensAtParsed := parseTime(&validation, "enrollment_opens_at", time.RFC3339, enrollmentOpensAt)
+	enrollmentClosesAtParsed := parseTime(&validation, "enrollment_closes_at", time.RFC3339, enrollmentClosesAt)
+
+	if err := validation.Err(); err != nil {
+		srv.log.DebugContext(ctx, "invalid course", "error", err, "id", id)
+		return nil, err
+	}
+
+	// validate the row as it will look after the partial update is applied
+	course, err := srv.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := policy.CanUpdateCourse(policy.FromContext(ctx), course, instructorId != nil); err != nil {
+		return nil, err
+	}
+
+	if name != nil {
+		course.Name = *name
+	}
+	if startDateParsed != nil {
+		course.StartDate = *startDateParsed
+	}
+	if endDateParsed != nil {
+		course.EndDate = *endDateParsed
+	}
+	if capacity != nil {
+		course.Capacity = *capacity
+	}
+	if enrollmentOpensAtParsed != nil {
+		course.EnrollmentOpensAt = enrollmentOpensAtParsed
+	}
+	if enrollmentClosesAtParsed != nil {
+		course.EnrollmentClosesAt = enrollmentClosesAtParsed
+	}
+
+	if err := course.Validate(); err != nil {
+		srv.log.DebugContext(ctx, "invalid course", "error", err, "id", id)
+		return nil, err
+	}
+
+	if err := srv.checkInstructor(ctx, instructorId); err != nil {
+		return nil, err
+	}
+
+	if err := srv.repository.Update(ctx, id, name, startDateParsed, endDateParsed, capacity, enrollmentOpensAtParsed, enrollmentClosesAtParsed, instructorId); err != nil {
+		return nil, err
+	}
+
+	return srv.repository.Get(ctx, id)
+}
+
+// Delete refuses to remove a course with open enrollments unless force is set,
+// in which case they are dropped along with it.
+func (srv *service) Delete(ctx context.Context, id string, force bool) error {
+	srv.log.DebugContext(ctx, "delete course")
+	if err := policy.CanDeleteCourse(policy.FromContext(ctx)); err != nil {
+		return err
+	}
+
+	return srv.repository.Transaction(ctx, func(repo Repository) error {
+		if err := repo.Lock(ctx, id); err != nil {
 			return err
 		}
-		startDateParsed = &parsed
-	}
 
-	var endDateParsed *time.Time
-	if endDate != nil {
-		parsed, err := time.Parse("2006-01-02", *endDate)
+		counts, err := repo.CountOpenEnrollments(ctx, id)
 		if err != nil {
-			srv.log.Println(err)
 			return err
 		}
-		endDateParsed = &parsed
+
+		if counts.Total > 0 {
+			if !force {
+				conflict := apperr.Conflict("course_has_enrollments", "course has open enrollments, delete it with force=true to drop them")
+				conflict.Details = counts
+				return conflict
+			}
+
+			if err := srv.enrollments.CancelForCourse(repo.WithTx(ctx), id); err != nil {
+				return err
+			}
+		}
+
+		return repo.Delete(ctx, id)
+	})
+}
+
+func (srv *service) Restore(ctx context.Context, id string) (*domain.Course, error) {
+	srv.log.DebugContext(ctx, "restore course")
+	if err := policy.CanManageDeleted(policy.FromContext(ctx)); err != nil {
+		return nil, err
+	}
+	if err := srv.repository.Restore(ctx, id); err != nil {
+		return nil, err
 	}
+	return srv.repository.Get(ctx, id)
+}
 
-	return srv.repository.Update(id, name, startDateParsed, endDateParsed)
+func (srv *service) DeletePermanently(ctx context.Context, id string) error {
+	srv.log.DebugContext(ctx, "delete course permanently")
+	if err := policy.CanManageDeleted(policy.FromContext(ctx)); err != nil {
+		return err
+	}
+	return srv.repository.DeletePermanently(ctx, id)
+}
+
+func (srv *service) Count(ctx context.Context, filters Filters) (int, error) {
+	srv.log.DebugContext(ctx, "count course")
+	if err := checkFilters(ctx, filters); err != nil {
+		return 0, err
+	}
+	return srv.repository.Count(ctx, filters)
 }
 
-func (srv *service) Delete(id string) error {
-	srv.log.Println("delete course service")
-	return srv.repository.Delete(id)
+func checkFilters(ctx context.Context, filters Filters) error {
+	if err := filters.Deleted.Validate(); err != nil {
+		return err
+	}
+	if filters.Deleted != domain.DeletedExclude {
+		return policy.CanManageDeleted(policy.FromContext(ctx))
+	}
+	return nil
 }
 
-func (srv *service) Count(filters Filters) (int, error) {
-	srv.log.Println("count course service")
-	return srv.repository.Count(filters)
+func (srv *service) checkInstructor(ctx context.Context, instructorId *string) error {
+	if instructorId == nil {
+		return nil
+	}
+
+	instructor, err := srv.userService.Get(ctx, *instructorId)
+	if err != nil {
+		return err
+	}
+
+	if instructor.Role != domain.RoleInstructor {
+		return apperr.Validation(apperr.FieldError{
+			Field:   "instructor_id",
+			Code:    "not_instructor",
+			Message: "User doesn't have the instructor role",
+		})
+	}
+	return nil
+}
+
+func parseTime(validation *apperr.FieldErrors, field, layout string, value *string) *time.Time {
+	if value == nil {
+		return nil
+	}
+
+	parsed, err := time.Parse(layout, *value)
+	if err != nil {
+		validation.Add(field, "invalid_format", fmt.Sprintf("Must use the %s format", layout))
+		return nil
+	}
+	return &parsed
 }
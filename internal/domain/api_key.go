@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Scope string
+
+const (
+	ScopeUsers       Scope = "users"
+	ScopeCourses     Scope = "courses"
+	ScopeEnrollments Scope = "enrollments"
+)
+
+var Scopes = []Scope{ScopeUsers, ScopeCourses, ScopeEnrollments}
+
+// ApiKey lets an integration call the API without a user login. Only the hash
+// of the key is stored, Prefix is kept so admins can tell keys apart.
+type ApiKey struct {
+	Id         string     `json:"id" gorm:"type:char(36);not null;primary_key"`
+	Name       string     `json:"name" gorm:"type:varchar(100);not null"`
+	Prefix     string     `json:"prefix" gorm:"type:varchar(16);not null"`
+	KeyHash    string     `json:"-" gorm:"type:char(64);not null;uniqueIndex"`
+	Scopes     []Scope    `json:"scopes" gorm:"type:jsonb;serializer:json;not null"`
+	CreatedBy  *string    `json:"created_by" gorm:"type:char(36)"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  *time.Time `json:"created_at"`
+}
+
+func (key *ApiKey) BeforeCreate(tx *gorm.DB) (err error) {
+	if key.Id == "" {
+		key.Id = uuid.New().String()
+	}
+	return
+}
+
+func (scope Scope) IsValid() bool {
+	return slices.Contains(Scopes, scope)
+}
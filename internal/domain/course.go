@@ -4,17 +4,27 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrEnrollmentNotYetOpen = apperr.Conflict("enrollment_not_open", "enrollment for this course is not open yet")
+	ErrEnrollmentClosed     = apperr.Conflict("enrollment_closed", "enrollment for this course is closed")
+)
+
 type Course struct {
-	Id        string         `json:"id" gorm:"type:char(36);not null;primary_key"`
-	Name      string         `json:"name" gorm:"type:varchar(50);not null"`
-	StartDate time.Time      `json:"start_date" gorm:"not null"`
-	EndDate   time.Time      `json:"end_date" gorm:"not null"`
-	CreatedAt *time.Time     `json:"-"`
-	UpdatedAt *time.Time     `json:"-"`
-	DeletedAt gorm.DeletedAt `json:"-"`
+	Id                 string         `json:"id" gorm:"type:char(36);not null;primary_key"`
+	Name               string         `json:"name" gorm:"type:varchar(50);not null"`
+	StartDate          time.Time      `json:"start_date" gorm:"not null"`
+	EndDate            time.Time      `json:"end_date" gorm:"not null"`
+	Capacity           int            `json:"capacity" gorm:"not null;default:0"`
+	InstructorId       *string        `json:"instructor_id,omitempty" gorm:"type:char(36);index"`
+	EnrollmentOpensAt  *time.Time     `json:"enrollment_opens_at,omitempty"`
+	EnrollmentClosesAt *time.Time     `json:"enrollment_closes_at,omitempty"`
+	CreatedAt          *time.Time     `json:"-"`
+	UpdatedAt          *time.Time     `json:"-"`
+	DeletedAt          gorm.DeletedAt `json:"deleted_at"`
 }
 
 func (course *Course) BeforeCreate(tx *gorm.DB) (err error) {
@@ -23,3 +33,46 @@ func (course *Course) BeforeCreate(tx *gorm.DB) (err error) {
 	}
 	return
 }
+
+// EnrollmentWindow returns when enrollment opens and closes for the course. A
+// nil opening time means enrollment is open right away, and when no closing
+// time is configured enrollment closes once the course starts.
+func (course *Course) EnrollmentWindow() (*time.Time, time.Time) {
+	if course.EnrollmentClosesAt != nil {
+		return course.EnrollmentOpensAt, *course.EnrollmentClosesAt
+	}
+	return course.EnrollmentOpensAt, course.StartDate
+}
+
+func (course *Course) CheckEnrollmentWindow(now time.Time) error {
+	opensAt, closesAt := course.EnrollmentWindow()
+	if opensAt != nil && now.Before(*opensAt) {
+		return ErrEnrollmentNotYetOpen
+	}
+	if !now.Before(closesAt) {
+		return ErrEnrollmentClosed
+	}
+	return nil
+}
+
+func (course *Course) Validate() error {
+	var validation apperr.FieldErrors
+
+	if course.EndDate.Before(course.StartDate) {
+		validation.Add("end_date", "before_start_date", "End date can't be before start date")
+	}
+
+	if course.Capacity < 0 {
+		validation.Add("capacity", "negative", "Capacity can't be negative")
+	}
+
+	if course.EnrollmentOpensAt != nil && course.EnrollmentClosesAt != nil && !course.EnrollmentOpensAt.Before(*course.EnrollmentClosesAt) {
+		validation.Add("enrollment_closes_at", "before_opens_at", "Enrollment close time must be after its open time")
+	}
+
+	if course.EnrollmentClosesAt != nil && course.EnrollmentClosesAt.After(course.EndDate) {
+		validation.Add("enrollment_closes_at", "after_end_date", "Enrollment can't close after the course ends")
+	}
+
+	return validation.Err()
+}
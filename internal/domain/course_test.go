@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+)
+
+func date(day int) time.Time {
+	return time.Date(2024, time.March, day, 0, 0, 0, 0, time.UTC)
+}
+
+func datePtr(day int) *time.Time {
+	value := date(day)
+	return &value
+}
+
+func TestCourseCheckEnrollmentWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		opensAt  *time.Time
+		closesAt *time.Time
+		now      time.Time
+		want     error
+	}{
+		{"open without an opening time", nil, nil, date(5), nil},
+		{"not open yet", datePtr(5), datePtr(8), date(4), ErrEnrollmentNotYetOpen},
+		{"open at the opening time", datePtr(5), datePtr(8), date(5), nil},
+		{"closed at the closing time", datePtr(5), datePtr(8), date(8), ErrEnrollmentClosed},
+		{"closes on the start date by default", nil, nil, date(10), ErrEnrollmentClosed},
+		{"open before the start date by default", datePtr(5), nil, date(9), nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			course := &Course{
+				StartDate:          date(10),
+				EndDate:            date(20),
+				EnrollmentOpensAt:  test.opensAt,
+				EnrollmentClosesAt: test.closesAt,
+			}
+
+			if err := course.CheckEnrollmentWindow(test.now); err != test.want {
+				t.Errorf("CheckEnrollmentWindow() = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestCourseValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(course *Course)
+		field  string
+		code   string
+	}{
+		{"valid", func(course *Course) {}, "", ""},
+		{"end before start", func(course *Course) { course.EndDate = date(9) }, "end_date", "before_start_date"},
+		{"one day course", func(course *Course) { course.EndDate = course.StartDate }, "", ""},
+		{"negative capacity", func(course *Course) { course.Capacity = -1 }, "capacity", "negative"},
+		{"closes before it opens", func(course *Course) { course.EnrollmentClosesAt = datePtr(4) }, "enrollment_closes_at", "before_opens_at"},
+		{"closes when it opens", func(course *Course) { course.EnrollmentClosesAt = datePtr(5) }, "enrollment_closes_at", "before_opens_at"},
+		{"closes after the end date", func(course *Course) { course.EnrollmentClosesAt = datePtr(21) }, "enrollment_closes_at", "after_end_date"},
+		{"closes on the end date", func(course *Course) { course.EnrollmentClosesAt = datePtr(20) }, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			course := &Course{
+				StartDate:          date(10),
+				EndDate:            date(20),
+				Capacity:           30,
+				EnrollmentOpensAt:  datePtr(5),
+				EnrollmentClosesAt: datePtr(8),
+			}
+			test.modify(course)
+
+			err := course.Validate()
+			if test.field == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+
+			var appErr *apperr.Error
+			if !errors.As(err, &appErr) || appErr.Kind != apperr.KindValidation {
+				t.Fatalf("Validate() = %v, want a validation error", err)
+			}
+			if len(appErr.Fields) != 1 || appErr.Fields[0].Field != test.field || appErr.Fields[0].Code != test.code {
+				t.Errorf("Validate() fields = %+v, want %s on %s", appErr.Fields, test.code, test.field)
+			}
+		})
+	}
+}
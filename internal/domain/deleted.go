@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"fmt"
+
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"gorm.io/gorm"
+)
+
+// DeletedFilter selects soft deleted rows in list queries. The zero value
+// leaves them out like every other query does.
+type DeletedFilter string
+
+const (
+	DeletedExclude DeletedFilter = ""
+	DeletedOnly    DeletedFilter = "only"
+	DeletedInclude DeletedFilter = "include"
+)
+
+func (filter DeletedFilter) Validate() error {
+	switch filter {
+	case DeletedExclude, DeletedOnly, DeletedInclude:
+		return nil
+	}
+	return apperr.Validation(apperr.FieldError{
+		Field:   "deleted",
+		Code:    "oneof",
+		Message: fmt.Sprintf("Must be one of: %s, %s", DeletedOnly, DeletedInclude),
+	})
+}
+
+func (filter DeletedFilter) Apply(tx *gorm.DB) *gorm.DB {
+	switch filter {
+	case DeletedOnly:
+		return tx.Unscoped().Where("deleted_at IS NOT NULL")
+	case DeletedInclude:
+		return tx.Unscoped()
+	}
+	return tx
+}
@@ -7,16 +7,39 @@ import (
 	"gorm.io/gorm"
 )
 
+type EnrollmentStatus string
+
+const (
+	EnrollmentPending    EnrollmentStatus = "P"
+	EnrollmentActive     EnrollmentStatus = "A"
+	EnrollmentStudying   EnrollmentStatus = "S"
+	EnrollmentCompleted  EnrollmentStatus = "C"
+	EnrollmentDropped    EnrollmentStatus = "D"
+	EnrollmentRejected   EnrollmentStatus = "R"
+	EnrollmentWaitlisted EnrollmentStatus = "W"
+)
+
+var enrollmentTransitions = map[EnrollmentStatus][]EnrollmentStatus{
+	EnrollmentWaitlisted: {EnrollmentPending, EnrollmentDropped},
+	EnrollmentPending:    {EnrollmentActive, EnrollmentRejected, EnrollmentDropped},
+	EnrollmentActive:     {EnrollmentStudying, EnrollmentDropped},
+	EnrollmentStudying:   {EnrollmentCompleted, EnrollmentDropped},
+	EnrollmentCompleted:  {},
+	EnrollmentDropped:    {},
+	EnrollmentRejected:   {},
+}
+
 type Enrollment struct {
-	Id        string         `json:"id" gorm:"type:char(36);not null;primary_key"`
-	UserId    string         `json:"user_id,omitempty" gorm:"type:char(36);not null"`
-	User      *User          `json:"user,omitempty"`
-	CourseId  string         `json:"course_id,omitempty" gorm:"type:char(36);not null"`
-	Course    *Course        `json:"course,omitempty"`
-	Status    string         `json:"status" gorm:"type:char(2)"`
-	CreatedAt *time.Time     `json:"-"`
-	UpdatedAt *time.Time     `json:"-"`
-	DeletedAt gorm.DeletedAt `json:"-"`
+	Id               string           `json:"id" gorm:"type:char(36);not null;primary_key"`
+	UserId           string           `json:"user_id,omitempty" gorm:"type:char(36);not null;uniqueIndex:idx_enrollments_user_course,where:deleted_at IS NULL AND status IN ('W'\\,'P'\\,'A'\\,'S')"`
+	User             *User            `json:"user,omitempty"`
+	CourseId         string           `json:"course_id,omitempty" gorm:"type:char(36);not null;uniqueIndex:idx_enrollments_user_course,where:deleted_at IS NULL AND status IN ('W'\\,'P'\\,'A'\\,'S')"`
+	Course           *Course          `json:"course,omitempty"`
+	Status           EnrollmentStatus `json:"status" gorm:"type:varchar(2);not null"`
+	WaitlistPosition *int             `json:"waitlist_position,omitempty"`
+	CreatedAt        *time.Time       `json:"-"`
+	UpdatedAt        *time.Time       `json:"-"`
+	DeletedAt        gorm.DeletedAt   `json:"-"`
 }
 
 func (enrollment *Enrollment) BeforeCreate(tx *gorm.DB) (err error) {
@@ -25,3 +48,33 @@ func (enrollment *Enrollment) BeforeCreate(tx *gorm.DB) (err error) {
 	}
 	return
 }
+
+func (status EnrollmentStatus) IsValid() bool {
+	_, ok := enrollmentTransitions[status]
+	return ok
+}
+
+func (status EnrollmentStatus) CanTransitionTo(next EnrollmentStatus) bool {
+	for _, allowed := range enrollmentTransitions[status] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenEnrollmentStatuses lists the statuses an enrollment can still move on
+// from, the ones that have to be dealt with when its user or course goes away.
+func OpenEnrollmentStatuses() []EnrollmentStatus {
+	return []EnrollmentStatus{EnrollmentWaitlisted, EnrollmentPending, EnrollmentActive, EnrollmentStudying}
+}
+
+// HoldsSeat reports whether an enrollment in this status counts against the
+// course capacity.
+func (status EnrollmentStatus) HoldsSeat() bool {
+	switch status {
+	case EnrollmentPending, EnrollmentActive, EnrollmentStudying:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package domain
+
+import "testing"
+
+func TestEnrollmentStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to EnrollmentStatus
+		want     bool
+	}{
+		{EnrollmentWaitlisted, EnrollmentPending, true},
+		{EnrollmentWaitlisted, EnrollmentDropped, true},
+		{EnrollmentWaitlisted, EnrollmentActive, false},
+		{EnrollmentPending, EnrollmentActive, true},
+		{EnrollmentPending, EnrollmentRejected, true},
+		{EnrollmentPending, EnrollmentDropped, true},
+		{EnrollmentPending, EnrollmentCompleted, false},
+		{EnrollmentActive, EnrollmentStudying, true},
+		{EnrollmentActive, EnrollmentDropped, true},
+		{EnrollmentActive, EnrollmentPending, false},
+		{EnrollmentStudying, EnrollmentCompleted, true},
+		{EnrollmentStudying, EnrollmentDropped, true},
+		{EnrollmentStudying, EnrollmentActive, false},
+		{EnrollmentCompleted, EnrollmentDropped, false},
+		{EnrollmentDropped, EnrollmentPending, false},
+		{EnrollmentRejected, EnrollmentPending, false},
+		{EnrollmentPending, EnrollmentPending, false},
+		{EnrollmentStatus("X"), EnrollmentPending, false},
+	}
+
+	for _, test := range tests {
+		if got := test.from.CanTransitionTo(test.to); got != test.want {
+			t.Errorf("%q.CanTransitionTo(%q) = %v, want %v", test.from, test.to, got, test.want)
+		}
+	}
+}
+
+func TestEnrollmentStatusIsValid(t *testing.T) {
+	tests := []struct {
+		status EnrollmentStatus
+		want   bool
+	}{
+		{EnrollmentPending, true},
+		{EnrollmentWaitlisted, true},
+		{EnrollmentCompleted, true},
+		{EnrollmentStatus(""), false},
+		{EnrollmentStatus("P "), false},
+		{EnrollmentStatus("X"), false},
+	}
+
+	for _, test := range tests {
+		if got := test.status.IsValid(); got != test.want {
+			t.Errorf("%q.IsValid() = %v, want %v", test.status, got, test.want)
+		}
+	}
+}
+
+func TestOpenEnrollmentStatusesCanStillChange(t *testing.T) {
+	for _, status := range OpenEnrollmentStatuses() {
+		if !status.CanTransitionTo(EnrollmentDropped) {
+			t.Errorf("open status %q can't be dropped", status)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RefreshToken stores the hash of a refresh token handed out on login. Tokens
+// are single use, refreshing revokes the old one and issues a new one.
+type RefreshToken struct {
+	Id        string    `gorm:"type:char(36);not null;primary_key"`
+	UserId    string    `gorm:"type:char(36);not null;index"`
+	TokenHash string    `gorm:"type:char(64);not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	RevokedAt *time.Time
+	CreatedAt *time.Time
+}
+
+func (token *RefreshToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if token.Id == "" {
+		token.Id = uuid.New().String()
+	}
+	return
+}
+
+func (token *RefreshToken) Active(now time.Time) bool {
+	return token.RevokedAt == nil && now.Before(token.ExpiresAt)
+}
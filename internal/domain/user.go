@@ -1,21 +1,49 @@
 package domain
 
 import (
+	"errors"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+const (
+	PasswordMinLength = 8
+	// bcrypt ignores everything after the first 72 bytes
+	PasswordMaxLength = 72
+)
+
+type Role string
+
+const (
+	RoleAdmin      Role = "admin"
+	RoleInstructor Role = "instructor"
+	RoleStudent    Role = "student"
+)
+
+var ErrPasswordMismatch = apperr.Validation(apperr.FieldError{
+	Field:   "current_password",
+	Code:    "mismatch",
+	Message: "Current password is incorrect",
+})
+
 type User struct {
-	Id        string         `json:"id" gorm:"type:char(36);not null;primary_key"`
-	FirstName string         `json:"first_name" gorm:"type:varchar(100);not null"`
-	LastName  string         `json:"last_name" gorm:"type:varchar(100);not null"`
-	Email     string         `json:"email" gorm:"type:varchar(100);not null;unique"`
-	Phone     string         `json:"phone" gorm:"type:varchar(30);not null"`
-	CreatedAt *time.Time     `json:"-"`
-	UpdatedAt *time.Time     `json:"-"`
-	DeletedAt gorm.DeletedAt `json:"-"`
+	Id              string         `json:"id" gorm:"type:char(36);not null;primary_key"`
+	FirstName       string         `json:"first_name" gorm:"type:varchar(100);not null"`
+	LastName        string         `json:"last_name" gorm:"type:varchar(100);not null"`
+	Email           string         `json:"email" gorm:"type:varchar(100);not null"`
+	Phone           string         `json:"phone" gorm:"type:varchar(30);not null"`
+	Role            Role           `json:"role" gorm:"type:varchar(20);not null;default:student"`
+	PasswordHash    string         `json:"-" gorm:"type:varchar(255);not null;default:''"` // empty until the user sets a password
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`
+	CreatedAt       *time.Time     `json:"-"`
+	UpdatedAt       *time.Time     `json:"-"`
+	DeletedAt       gorm.DeletedAt `json:"deleted_at"`
 }
 
 func (user *User) BeforeCreate(tx *gorm.DB) (err error) {
@@ -24,3 +52,82 @@ func (user *User) BeforeCreate(tx *gorm.DB) (err error) {
 	}
 	return
 }
+
+// NormalizeEmail is applied to every email before it's stored or looked up,
+// addresses are compared case-insensitively.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (role Role) IsValid() bool {
+	switch role {
+	case RoleAdmin, RoleInstructor, RoleStudent:
+		return true
+	}
+	return false
+}
+
+func (user *User) HasPassword() bool {
+	return user.PasswordHash != ""
+}
+
+// SetPassword checks password against the password policy and stores its
+// bcrypt hash.
+func (user *User) SetPassword(password string) error {
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return apperr.Internal(err)
+	}
+
+	user.PasswordHash = string(hash)
+	return nil
+}
+
+// CheckPassword reports whether password matches the stored hash. Users
+// without a password never match.
+func (user *User) CheckPassword(password string) (bool, error) {
+	if !user.HasPassword() {
+		return false, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, apperr.Internal(err)
+	}
+	return true, nil
+}
+
+func ValidatePassword(password string) error {
+	var validation apperr.FieldErrors
+
+	if len(password) < PasswordMinLength {
+		validation.Add("password", "too_short", "Password must have at least 8 characters")
+	}
+
+	if len(password) > PasswordMaxLength {
+		validation.Add("password", "too_long", "Password can't be longer than 72 bytes")
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+
+	if !hasLetter || !hasDigit {
+		validation.Add("password", "too_weak", "Password must contain at least one letter and one digit")
+	}
+
+	return validation.Err()
+}
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TokenPurpose string
+
+const (
+	TokenEmailVerification TokenPurpose = "email_verification"
+	TokenPasswordReset     TokenPurpose = "password_reset"
+)
+
+// UserToken is a single use token mailed to a user, proving they own their
+// email address. Only its hash is stored.
+type UserToken struct {
+	Id        string       `gorm:"type:char(36);not null;primary_key"`
+	UserId    string       `gorm:"type:char(36);not null;index"`
+	Purpose   TokenPurpose `gorm:"type:varchar(30);not null"`
+	TokenHash string       `gorm:"type:char(64);not null;uniqueIndex"`
+	ExpiresAt time.Time    `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt *time.Time
+}
+
+func (token *UserToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if token.Id == "" {
+		token.Id = uuid.New().String()
+	}
+	return
+}
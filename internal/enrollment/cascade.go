@@ -0,0 +1,117 @@
+package enrollment
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/zchelalo/rest-api-go/internal/domain"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+)
+
+// Cascade settles the enrollments of a user or course being deleted. It only
+// needs the repository so the user and course services, which Service depends
+// on, can be handed one.
+//
+// Both cascades follow the same rule: open enrollments are marked dropped and
+// every enrollment is kept, so the history survives and restoring the user or
+// course brings it back as it was left.
+type Cascade struct {
+	log        *slog.Logger
+	repository Repository
+}
+
+func NewCascade(repo Repository, log *slog.Logger) *Cascade {
+	return &Cascade{
+		log:        log,
+		repository: repo,
+	}
+}
+
+// CancelForUser drops the open enrollments of the user and hands the seats
+// they held to the waitlists, the same way dropping them one by one does. It
+// joins the transaction carried by ctx, if any.
+func (cascade *Cascade) CancelForUser(ctx context.Context, userId string) error {
+	var count int
+	dropped := domain.EnrollmentDropped
+	err := cascade.repository.Transaction(ctx, func(repo Repository) error {
+		enrollments, err := repo.GetOpenByUser(ctx, userId)
+		if err != nil {
+			return err
+		}
+		count = len(enrollments)
+
+		for _, open := range enrollments {
+			course, err := repo.LockCourse(ctx, open.CourseId)
+			if err != nil && apperr.From(err).Kind != apperr.KindNotFound {
+				return err
+			}
+
+			// the status may have changed before the course was locked
+			enrollment, err := repo.Get(ctx, open.Id)
+			if err != nil {
+				return err
+			}
+			if !enrollment.Status.CanTransitionTo(dropped) {
+				continue
+			}
+
+			if err := repo.Update(ctx, enrollment.Id, &dropped); err != nil {
+				return err
+			}
+
+			// a deleted course has no waitlist left to move up
+			if course == nil {
+				continue
+			}
+			if err := releaseSeat(ctx, cascade.log, repo, course, enrollment, dropped); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		cascade.log.InfoContext(ctx, "user enrollments canceled", "user_id", userId, "count", count)
+	}
+	return nil
+}
+
+// CancelForCourse drops the open enrollments of the course. The whole course
+// goes away, waitlist included, so no seat is handed over. It joins the
+// transaction carried by ctx, if any.
+func (cascade *Cascade) CancelForCourse(ctx context.Context, courseId string) error {
+	var count int
+	dropped := domain.EnrollmentDropped
+	err := cascade.repository.Transaction(ctx, func(repo Repository) error {
+		if _, err := repo.LockCourse(ctx, courseId); err != nil {
+			return err
+		}
+
+		enrollments, err := repo.GetOpenByCourse(ctx, courseId)
+		if err != nil {
+			return err
+		}
+
+		for _, enrollment := range enrollments {
+			if !enrollment.Status.CanTransitionTo(dropped) {
+				continue
+			}
+			if err := repo.Update(ctx, enrollment.Id, &dropped); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		cascade.log.InfoContext(ctx, "course enrollments canceled", "course_id", courseId, "count", count)
+	}
+	return nil
+}
@@ -4,40 +4,46 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
 	"github.com/zchelalo/rest-api-go/pkg/meta"
-)
-
-type status string
-
-const (
-	statusSuccess status = "success"
-	statusError   status = "error"
+	"github.com/zchelalo/rest-api-go/pkg/response"
+	"github.com/zchelalo/rest-api-go/pkg/validator"
 )
 
 type (
 	Controller func(w http.ResponseWriter, req *http.Request)
 
+	Config struct {
+		LimPageDef int
+	}
+
 	Endpoints struct {
 		Create Controller
+		GetAll Controller
+		Get    Controller
+		Update Controller
+		Delete Controller
 	}
 
 	CreateRequest struct {
-		UserId   string `json:"user_id"`
-		CourseId string `json:"course_id"`
+		UserId   string `json:"user_id" validate:"required,uuid"`
+		CourseId string `json:"course_id" validate:"required,uuid"`
 	}
 
-	Response struct {
-		Status status      `json:"status"`
-		Data   interface{} `json:"data,omitempty"`
-		Error  string      `json:"error,omitempty"`
-		Meta   *meta.Meta  `json:"meta,omitempty"`
+	UpdateRequest struct {
+		Status *string `json:"status" validate:"required,max=2"`
 	}
 )
 
-func MakeEndpoints(service Service) Endpoints {
+func MakeEndpoints(service Service, config Config) Endpoints {
 	return Endpoints{
 		Create: makeCreateEndpoint(service),
+		GetAll: makeGetAllEndpoint(service, config),
+		Get:    makeGetEndpoint(service),
+		Update: makeUpdateEndpoint(service),
+		Delete: makeDeleteEndpoint(service),
 	}
 }
 
@@ -45,46 +51,103 @@ func makeCreateEndpoint(service Service) Controller {
 	return func(w http.ResponseWriter, req *http.Request) {
 		var request CreateRequest
 		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(Response{
-				Status: statusError,
-				Error:  fmt.Sprintf("Invalid request format, %v", err.Error()),
-			})
+			response.Error(w, apperr.BadRequest("invalid_request", fmt.Sprintf("Invalid request format, %v", err.Error())))
 			return
 		}
 
-		if request.UserId == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(Response{
-				Status: statusError,
-				Error:  "User id is required",
-			})
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		enrollment, err := service.Create(req.Context(), request.UserId, request.CourseId)
+		if err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		response.Created(w, enrollment)
+	}
+}
+
+func makeGetAllEndpoint(service Service, config Config) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		queries := req.URL.Query()
+		filters := Filters{
+			UserId:   queries.Get("user_id"),
+			CourseId: queries.Get("course_id"),
+			Status:   queries.Get("status"),
+		}
+
+		limit, _ := strconv.Atoi(queries.Get("limit"))
+		page, _ := strconv.Atoi(queries.Get("page"))
+
+		count, err := service.Count(req.Context(), filters)
+		if err != nil {
+			response.Error(w, err)
 			return
 		}
+		meta := meta.New(page, limit, count, config.LimPageDef)
 
-		if request.CourseId == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(Response{
-				Status: statusError,
-				Error:  "Course id is required",
-			})
+		enrollments, err := service.GetAll(req.Context(), filters, meta.Offset(), meta.Limit())
+		if err != nil {
+			response.Error(w, err)
 			return
 		}
 
-		enrollment, err := service.Create(request.UserId, request.CourseId)
+		response.OK(w, enrollments, meta)
+	}
+}
+
+func makeGetEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id := req.PathValue("id")
+
+		enrollment, err := service.Get(req.Context(), id)
+		if err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		response.OK(w, enrollment, nil)
+	}
+}
+
+func makeUpdateEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id := req.PathValue("id")
+
+		var request UpdateRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			response.Error(w, apperr.BadRequest("invalid_request", fmt.Sprintf("Invalid request format, %v", err.Error())))
+			return
+		}
+
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		enrollment, err := service.Update(req.Context(), id, request.Status)
+		if err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		response.OK(w, enrollment, nil)
+	}
+}
+
+func makeDeleteEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id := req.PathValue("id")
+
+		err := service.Delete(req.Context(), id)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(Response{
-				Status: statusError,
-				Error:  err.Error(),
-			})
+			response.Error(w, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(Response{
-			Status: statusSuccess,
-			Data:   enrollment,
-		})
+		response.OK(w, "Enrollment deleted successfully", nil)
 	}
 }
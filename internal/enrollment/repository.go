@@ -1,36 +1,264 @@
 package enrollment
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 
 	"github.com/zchelalo/rest-api-go/internal/domain"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"github.com/zchelalo/rest-api-go/pkg/database"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type (
 	Repository interface {
-		Create(enrollment *domain.Enrollment) error
+		Create(ctx context.Context, enrollment *domain.Enrollment) error
+		GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Enrollment, error)
+		Get(ctx context.Context, id string) (*domain.Enrollment, error)
+		GetOpenByUser(ctx context.Context, userId string) ([]domain.Enrollment, error)
+		GetOpenByCourse(ctx context.Context, courseId string) ([]domain.Enrollment, error)
+		Update(ctx context.Context, id string, status *domain.EnrollmentStatus) error
+		Delete(ctx context.Context, id string) error
+		Count(ctx context.Context, filters Filters) (int, error)
+		Transaction(ctx context.Context, fn func(repo Repository) error) error
+		LockCourse(ctx context.Context, courseId string) (*domain.Course, error)
+		CountSeats(ctx context.Context, courseId string) (int, error)
+		LastWaitlistPosition(ctx context.Context, courseId string) (int, error)
+		NextWaitlisted(ctx context.Context, courseId string) (*domain.Enrollment, error)
+		ShiftWaitlist(ctx context.Context, courseId string, after int) error
 	}
 
 	repository struct {
 		db  *gorm.DB
-		log *log.Logger
+		log *slog.Logger
 	}
 )
 
-func NewRepository(log *log.Logger, db *gorm.DB) Repository {
+func NewRepository(log *slog.Logger, db *gorm.DB) Repository {
 	return &repository{
 		log: log,
 		db:  db,
 	}
 }
 
-func (repo *repository) Create(enrollment *domain.Enrollment) error {
-	if err := repo.db.Create(enrollment).Error; err != nil {
-		repo.log.Printf("error: %v", err)
+func (repo *repository) Create(ctx context.Context, enrollment *domain.Enrollment) error {
+	if err := repo.db.WithContext(ctx).Create(enrollment).Error; err != nil {
+		repo.log.ErrorContext(ctx, "create enrollment", "error", err, "id", enrollment.Id)
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrAlreadyEnrolled
+		}
+		return apperr.FromDB(err, "enrollment")
+	}
+
+	repo.log.InfoContext(ctx, "enrollment created", "id", enrollment.Id)
+	return nil
+}
+
+func (repo *repository) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Enrollment, error) {
+	var enrollments []domain.Enrollment
+
+	tx := repo.db.WithContext(ctx).Model(&enrollments)
+	tx = applyFilters(tx, filters)
+	tx = tx.Limit(limit).Offset(offset)
+	if err := tx.Order("created_at desc").Find(&enrollments).Error; err != nil {
+		repo.log.ErrorContext(ctx, "get all enrollments", "error", err)
+		return nil, apperr.FromDB(err, "enrollment")
+	}
+
+	return enrollments, nil
+}
+
+func (repo *repository) Get(ctx context.Context, id string) (*domain.Enrollment, error) {
+	enrollment := domain.Enrollment{
+		Id: id,
+	}
+
+	if err := repo.db.WithContext(ctx).Model(&enrollment).Preload("User").Preload("Course").First(&enrollment).Error; err != nil {
+		repo.log.ErrorContext(ctx, "get enrollment", "error", err, "id", id)
+		return nil, apperr.FromDB(err, "enrollment")
+	}
+
+	return &enrollment, nil
+}
+
+func (repo *repository) GetOpenByUser(ctx context.Context, userId string) ([]domain.Enrollment, error) {
+	var enrollments []domain.Enrollment
+
+	tx := repo.db.WithContext(ctx).Where("user_id = ? AND status IN ?", userId, domain.OpenEnrollmentStatuses()).Order("course_id")
+	if err := tx.Find(&enrollments).Error; err != nil {
+		repo.log.ErrorContext(ctx, "get open enrollments", "error", err, "user_id", userId)
+		return nil, apperr.FromDB(err, "enrollment")
+	}
+
+	return enrollments, nil
+}
+
+func (repo *repository) GetOpenByCourse(ctx context.Context, courseId string) ([]domain.Enrollment, error) {
+	var enrollments []domain.Enrollment
+
+	tx := repo.db.WithContext(ctx).Where("course_id = ? AND status IN ?", courseId, domain.OpenEnrollmentStatuses())
+	if err := tx.Find(&enrollments).Error; err != nil {
+		repo.log.ErrorContext(ctx, "get open enrollments", "error", err, "course_id", courseId)
+		return nil, apperr.FromDB(err, "enrollment")
+	}
+
+	return enrollments, nil
+}
+
+func (repo *repository) Update(ctx context.Context, id string, status *domain.EnrollmentStatus) error {
+	values := make(map[string]interface{})
+
+	if status != nil {
+		values["status"] = *status
+		// a waitlist position only makes sense while the enrollment is waitlisted
+		if *status != domain.EnrollmentWaitlisted {
+			values["waitlist_position"] = nil
+		}
+	}
+
+	if len(values) == 0 {
+		_, err := repo.Get(ctx, id)
 		return err
 	}
 
-	repo.log.Println("enrollment created with id: ", enrollment.Id)
+	result := repo.db.WithContext(ctx).Model(&domain.Enrollment{}).Where("id = ?", id).Updates(values)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "update enrollment", "error", result.Error, "id", id)
+		return apperr.FromDB(result.Error, "enrollment")
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.FromDB(gorm.ErrRecordNotFound, "enrollment")
+	}
+
+	return nil
+}
+
+func (repo *repository) Delete(ctx context.Context, id string) error {
+	enrollment := domain.Enrollment{
+		Id: id,
+	}
+
+	result := repo.db.WithContext(ctx).Model(&enrollment).Delete(&enrollment)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "delete enrollment", "error", result.Error, "id", id)
+		return apperr.FromDB(result.Error, "enrollment")
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.FromDB(gorm.ErrRecordNotFound, "enrollment")
+	}
+
+	return nil
+}
+
+func (repo *repository) Count(ctx context.Context, filters Filters) (int, error) {
+	var count int64
+	tx := repo.db.WithContext(ctx).Model(&domain.Enrollment{})
+	tx = applyFilters(tx, filters)
+	if err := tx.Count(&count).Error; err != nil {
+		repo.log.ErrorContext(ctx, "count enrollments", "error", err)
+		return 0, apperr.FromDB(err, "enrollment")
+	}
+
+	return int(count), nil
+}
+
+func applyFilters(tx *gorm.DB, filters Filters) *gorm.DB {
+	if filters.UserId != "" {
+		tx = tx.Where("user_id = ?", filters.UserId)
+	}
+
+	if filters.CourseId != "" {
+		tx = tx.Where("course_id = ?", filters.CourseId)
+	}
+
+	if filters.Status != "" {
+		tx = tx.Where("status = ?", filters.Status)
+	}
+
+	if filters.Open {
+		tx = tx.Where("status IN ?", domain.OpenEnrollmentStatuses())
+	}
+
+	return tx
+}
+
+// Transaction joins the transaction carried by ctx, if any, so work started
+// from another package commits or rolls back along with it.
+func (repo *repository) Transaction(ctx context.Context, fn func(repo Repository) error) error {
+	return database.Conn(ctx, repo.db).Transaction(func(tx *gorm.DB) error {
+		return fn(&repository{
+			log: repo.log,
+			db:  tx,
+		})
+	})
+}
+
+func (repo *repository) LockCourse(ctx context.Context, courseId string) (*domain.Course, error) {
+	var course domain.Course
+
+	if err := repo.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", courseId).First(&course).Error; err != nil {
+		repo.log.ErrorContext(ctx, "lock course", "error", err, "course_id", courseId)
+		return nil, apperr.FromDB(err, "course")
+	}
+
+	return &course, nil
+}
+
+func (repo *repository) CountSeats(ctx context.Context, courseId string) (int, error) {
+	var count int64
+	tx := repo.db.WithContext(ctx).Model(&domain.Enrollment{}).
+		Where("course_id = ?", courseId).
+		Where("status IN ?", []domain.EnrollmentStatus{domain.EnrollmentPending, domain.EnrollmentActive, domain.EnrollmentStudying})
+	if err := tx.Count(&count).Error; err != nil {
+		repo.log.ErrorContext(ctx, "count course seats", "error", err, "course_id", courseId)
+		return 0, apperr.FromDB(err, "enrollment")
+	}
+
+	return int(count), nil
+}
+
+func (repo *repository) LastWaitlistPosition(ctx context.Context, courseId string) (int, error) {
+	var position int
+	tx := repo.db.WithContext(ctx).Model(&domain.Enrollment{}).
+		Select("COALESCE(MAX(waitlist_position), 0)").
+		Where("course_id = ? AND status = ?", courseId, domain.EnrollmentWaitlisted)
+	if err := tx.Scan(&position).Error; err != nil {
+		repo.log.ErrorContext(ctx, "get last waitlist position", "error", err, "course_id", courseId)
+		return 0, apperr.FromDB(err, "enrollment")
+	}
+
+	return position, nil
+}
+
+func (repo *repository) NextWaitlisted(ctx context.Context, courseId string) (*domain.Enrollment, error) {
+	var enrollments []domain.Enrollment
+	tx := repo.db.WithContext(ctx).Model(&domain.Enrollment{}).
+		Where("course_id = ? AND status = ?", courseId, domain.EnrollmentWaitlisted).
+		Order("waitlist_position asc").
+		Limit(1)
+	if err := tx.Find(&enrollments).Error; err != nil {
+		repo.log.ErrorContext(ctx, "get next waitlisted enrollment", "error", err, "course_id", courseId)
+		return nil, apperr.FromDB(err, "enrollment")
+	}
+
+	if len(enrollments) == 0 {
+		return nil, nil
+	}
+
+	return &enrollments[0], nil
+}
+
+func (repo *repository) ShiftWaitlist(ctx context.Context, courseId string, after int) error {
+	tx := repo.db.WithContext(ctx).Model(&domain.Enrollment{}).
+		Where("course_id = ? AND status = ? AND waitlist_position > ?", courseId, domain.EnrollmentWaitlisted, after)
+	if err := tx.Update("waitlist_position", gorm.Expr("waitlist_position - 1")).Error; err != nil {
+		repo.log.ErrorContext(ctx, "shift waitlist", "error", err, "course_id", courseId)
+		return apperr.FromDB(err, "enrollment")
+	}
+
 	return nil
 }
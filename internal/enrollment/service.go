@@ -1,28 +1,50 @@
 package enrollment
 
 import (
-	"errors"
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/zchelalo/rest-api-go/internal/course"
 	"github.com/zchelalo/rest-api-go/internal/domain"
+	"github.com/zchelalo/rest-api-go/internal/policy"
 	"github.com/zchelalo/rest-api-go/internal/user"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+)
+
+var (
+	ErrAlreadyEnrolled = apperr.Conflict("already_enrolled", "user is already enrolled in this course")
+	ErrCourseFull      = apperr.Conflict("course_full", "course has no seats available")
 )
 
 type (
+	Filters struct {
+		UserId   string
+		CourseId string
+		Status   string
+		// Open keeps only the enrollments in domain.OpenEnrollmentStatuses.
+		Open bool
+	}
+
 	Service interface {
-		Create(userId, courseId string) (*domain.Enrollment, error)
+		Create(ctx context.Context, userId, courseId string) (*domain.Enrollment, error)
+		GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Enrollment, error)
+		Get(ctx context.Context, id string) (*domain.Enrollment, error)
+		Update(ctx context.Context, id string, status *string) (*domain.Enrollment, error)
+		Delete(ctx context.Context, id string) error
+		Count(ctx context.Context, filters Filters) (int, error)
 	}
 
 	service struct {
-		log           *log.Logger
+		log           *slog.Logger
 		userService   user.Service
 		courseService course.Service
 		repository    Repository
 	}
 )
 
-func NewService(repo Repository, log *log.Logger, userService user.Service, courseService course.Service) Service {
+func NewService(repo Repository, log *slog.Logger, userService user.Service, courseService course.Service) Service {
 	return &service{
 		repository:    repo,
 		log:           log,
@@ -31,25 +53,241 @@ func NewService(repo Repository, log *log.Logger, userService user.Service, cour
 	}
 }
 
-func (srv service) Create(userId, courseId string) (*domain.Enrollment, error) {
+func (srv service) Create(ctx context.Context, userId, courseId string) (*domain.Enrollment, error) {
+	if err := policy.CanEnroll(policy.FromContext(ctx), userId); err != nil {
+		return nil, err
+	}
+
 	enrollment := &domain.Enrollment{
 		UserId:   userId,
 		CourseId: courseId,
-		Status:   "P",
+		Status:   domain.EnrollmentPending,
 	}
 
-	if _, err := srv.userService.Get(enrollment.UserId); err != nil {
-		return nil, errors.New("user id doesn't exists")
+	if _, err := srv.userService.Get(ctx, enrollment.UserId); err != nil {
+		return nil, err
 	}
 
-	if _, err := srv.courseService.Get(enrollment.CourseId); err != nil {
-		return nil, errors.New("course id doesn't exists")
+	if _, err := srv.courseService.Get(ctx, enrollment.CourseId); err != nil {
+		return nil, err
+	}
+
+	// dropped, rejected and completed enrollments don't keep the user from
+	// enrolling again
+	count, err := srv.repository.Count(ctx, Filters{
+		UserId:   enrollment.UserId,
+		CourseId: enrollment.CourseId,
+		Open:     true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrAlreadyEnrolled
+	}
+
+	err = srv.repository.Transaction(ctx, func(repo Repository) error {
+		course, err := repo.LockCourse(ctx, enrollment.CourseId)
+		if err != nil {
+			return err
+		}
+
+		if err := course.CheckEnrollmentWindow(time.Now()); err != nil {
+			return err
+		}
+
+		if course.Capacity > 0 {
+			seats, err := repo.CountSeats(ctx, course.Id)
+			if err != nil {
+				return err
+			}
+
+			if seats >= course.Capacity {
+				position, err := repo.LastWaitlistPosition(ctx, course.Id)
+				if err != nil {
+					return err
+				}
+				position++
+
+				enrollment.Status = domain.EnrollmentWaitlisted
+				enrollment.WaitlistPosition = &position
+			}
+		}
+
+		return repo.Create(ctx, enrollment)
+	})
+	if err != nil {
+		srv.log.DebugContext(ctx, "enrollment not created", "error", err, "user_id", userId, "course_id", courseId)
+		return nil, err
 	}
 
-	if err := srv.repository.Create(enrollment); err != nil {
-		srv.log.Println(err)
+	return enrollment, nil
+}
+
+func (srv service) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Enrollment, error) {
+	srv.log.DebugContext(ctx, "get all enrollments")
+	enrollments, err := srv.repository.GetAll(ctx, scopeFilters(ctx, filters), offset, limit)
+	if err != nil {
 		return nil, err
 	}
+	return enrollments, nil
+}
 
+func (srv service) Get(ctx context.Context, id string) (*domain.Enrollment, error) {
+	srv.log.DebugContext(ctx, "get enrollment")
+	enrollment, err := srv.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.CanReadEnrollment(policy.FromContext(ctx), enrollment); err != nil {
+		return nil, err
+	}
 	return enrollment, nil
 }
+
+func (srv service) Update(ctx context.Context, id string, status *string) (*domain.Enrollment, error) {
+	srv.log.DebugContext(ctx, "update enrollment")
+
+	if status == nil {
+		return srv.Get(ctx, id)
+	}
+
+	next := domain.EnrollmentStatus(*status)
+	if !next.IsValid() {
+		return nil, apperr.Validation(apperr.FieldError{
+			Field:   "status",
+			Code:    "invalid",
+			Message: fmt.Sprintf("%s is not a valid enrollment status", *status),
+		})
+	}
+
+	current, err := srv.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = srv.repository.Transaction(ctx, func(repo Repository) error {
+		course, err := repo.LockCourse(ctx, current.CourseId)
+		if err != nil {
+			return err
+		}
+
+		enrollment, err := repo.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := policy.CanUpdateEnrollment(policy.FromContext(ctx), enrollment, course, next); err != nil {
+			return err
+		}
+
+		if !enrollment.Status.CanTransitionTo(next) {
+			return apperr.Conflict("invalid_status_transition", fmt.Sprintf("enrollment status can't change from %s to %s", enrollment.Status, next))
+		}
+
+		if !enrollment.Status.HoldsSeat() && next.HoldsSeat() && course.Capacity > 0 {
+			seats, err := repo.CountSeats(ctx, course.Id)
+			if err != nil {
+				return err
+			}
+			if seats >= course.Capacity {
+				return ErrCourseFull
+			}
+		}
+
+		if err := repo.Update(ctx, id, &next); err != nil {
+			return err
+		}
+
+		return releaseSeat(ctx, srv.log, repo, course, enrollment, next)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return srv.repository.Get(ctx, id)
+}
+
+func (srv service) Delete(ctx context.Context, id string) error {
+	srv.log.DebugContext(ctx, "delete enrollment")
+
+	current, err := srv.repository.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return srv.repository.Transaction(ctx, func(repo Repository) error {
+		course, err := repo.LockCourse(ctx, current.CourseId)
+		if err != nil {
+			return err
+		}
+
+		if err := policy.CanDeleteEnrollment(policy.FromContext(ctx), course); err != nil {
+			return err
+		}
+
+		enrollment, err := repo.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := repo.Delete(ctx, id); err != nil {
+			return err
+		}
+
+		return releaseSeat(ctx, srv.log, repo, course, enrollment, domain.EnrollmentDropped)
+	})
+}
+
+func (srv service) Count(ctx context.Context, filters Filters) (int, error) {
+	srv.log.DebugContext(ctx, "count enrollment")
+	return srv.repository.Count(ctx, scopeFilters(ctx, filters))
+}
+
+// scopeFilters limits students to listing their own enrollments.
+func scopeFilters(ctx context.Context, filters Filters) Filters {
+	actor := policy.FromContext(ctx)
+	if !policy.CanListAllEnrollments(actor) {
+		filters.UserId = actor.UserId
+	}
+	return filters
+}
+
+// releaseSeat keeps the waitlist consistent after an enrollment moves from its
+// previous status to next. It must run inside a transaction holding the course
+// lock.
+func releaseSeat(ctx context.Context, log *slog.Logger, repo Repository, course *domain.Course, previous *domain.Enrollment, next domain.EnrollmentStatus) error {
+	if previous.Status == domain.EnrollmentWaitlisted && next != domain.EnrollmentWaitlisted && previous.WaitlistPosition != nil {
+		return repo.ShiftWaitlist(ctx, course.Id, *previous.WaitlistPosition)
+	}
+
+	if !previous.Status.HoldsSeat() || next.HoldsSeat() {
+		return nil
+	}
+
+	if course.Capacity > 0 {
+		seats, err := repo.CountSeats(ctx, course.Id)
+		if err != nil {
+			return err
+		}
+		if seats >= course.Capacity {
+			return nil
+		}
+	}
+
+	waitlisted, err := repo.NextWaitlisted(ctx, course.Id)
+	if err != nil || waitlisted == nil {
+		return err
+	}
+
+	pending := domain.EnrollmentPending
+	if err := repo.Update(ctx, waitlisted.Id, &pending); err != nil {
+		return err
+	}
+
+	log.InfoContext(ctx, "enrollment promoted from waitlist", "id", waitlisted.Id, "course_id", course.Id)
+	if waitlisted.WaitlistPosition == nil {
+		return nil
+	}
+	return repo.ShiftWaitlist(ctx, course.Id, *waitlisted.WaitlistPosition)
+}
@@ -0,0 +1,41 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/zchelalo/rest-api-go/pkg/response"
+)
+
+type (
+	Controller func(w http.ResponseWriter, req *http.Request)
+
+	Endpoints struct {
+		Liveness  Controller
+		Readiness Controller
+	}
+)
+
+func MakeEndpoints(service Service) Endpoints {
+	return Endpoints{
+		Liveness:  makeLivenessEndpoint(),
+		Readiness: makeReadinessEndpoint(service),
+	}
+}
+
+func makeLivenessEndpoint() Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		response.OK(w, map[string]string{"status": statusUp}, nil)
+	}
+}
+
+func makeReadinessEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		report, ready := service.Ready(req.Context())
+		if !ready {
+			response.Unavailable(w, report)
+			return
+		}
+
+		response.OK(w, report, nil)
+	}
+}
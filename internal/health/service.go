@@ -0,0 +1,121 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/zchelalo/rest-api-go/pkg/migrate"
+	"gorm.io/gorm"
+)
+
+const (
+	statusUp   = "up"
+	statusDown = "down"
+
+	pingTimeout = 2 * time.Second
+)
+
+type (
+	Check struct {
+		Status string `json:"status"`
+		Error  string `json:"error,omitempty"`
+	}
+
+	Report struct {
+		Status           string           `json:"status"`
+		MigrationVersion int64            `json:"migration_version"`
+		Checks           map[string]Check `json:"checks"`
+	}
+
+	Service interface {
+		Ready(ctx context.Context) (*Report, bool)
+		SetShuttingDown()
+	}
+
+	service struct {
+		log          *slog.Logger
+		db           *gorm.DB
+		migrator     *migrate.Migrator
+		shuttingDown atomic.Bool
+	}
+)
+
+func NewService(log *slog.Logger, db *gorm.DB, migrator *migrate.Migrator) Service {
+	return &service{
+		log:      log,
+		db:       db,
+		migrator: migrator,
+	}
+}
+
+// Ready reports the state of every dependency and whether the instance should
+// keep receiving traffic.
+func (srv *service) Ready(ctx context.Context) (*Report, bool) {
+	report := &Report{
+		Status: statusUp,
+		Checks: map[string]Check{},
+	}
+
+	if srv.shuttingDown.Load() {
+		report.Checks["server"] = Check{Status: statusDown, Error: "shutting down"}
+	} else {
+		report.Checks["server"] = Check{Status: statusUp}
+	}
+
+	report.Checks["database"] = srv.pingDatabase(ctx)
+
+	if report.Checks["database"].Status == statusUp {
+		report.Checks["migrations"] = srv.checkMigrations(ctx, report)
+	} else {
+		report.Checks["migrations"] = Check{Status: statusDown, Error: "database unavailable"}
+	}
+
+	for _, check := range report.Checks {
+		if check.Status != statusUp {
+			report.Status = statusDown
+		}
+	}
+
+	return report, report.Status == statusUp
+}
+
+func (srv *service) SetShuttingDown() {
+	srv.shuttingDown.Store(true)
+}
+
+func (srv *service) pingDatabase(ctx context.Context) Check {
+	sqlDB, err := srv.db.DB()
+	if err != nil {
+		return Check{Status: statusDown, Error: err.Error()}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		srv.log.Warn("readiness database ping", "error", err)
+		return Check{Status: statusDown, Error: err.Error()}
+	}
+	return Check{Status: statusUp}
+}
+
+func (srv *service) checkMigrations(ctx context.Context, report *Report) Check {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	state, err := srv.migrator.State(ctx)
+	if err != nil {
+		srv.log.Warn("readiness migrations check", "error", err)
+		return Check{Status: statusDown, Error: err.Error()}
+	}
+	report.MigrationVersion = state.Version
+
+	if len(state.Pending) > 0 {
+		return Check{Status: statusDown, Error: fmt.Sprintf("%v, pending migrations: %s", migrate.ErrSchemaBehind, strings.Join(state.Pending, ", "))}
+	}
+	return Check{Status: statusUp}
+}
@@ -0,0 +1,197 @@
+// Package policy holds the authorization rules. Every check is a plain
+// function of the caller and the records involved so it can be exercised
+// without going through HTTP.
+package policy
+
+import (
+	"context"
+
+	"github.com/zchelalo/rest-api-go/internal/domain"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"github.com/zchelalo/rest-api-go/pkg/middleware"
+)
+
+var ErrForbidden = apperr.Forbidden("forbidden", "you are not allowed to perform this action")
+
+// Actor is the caller a policy is evaluated for, either a user or an api key.
+// The zero value is an anonymous caller.
+type Actor struct {
+	UserId   string
+	ApiKeyId string
+	Role     domain.Role
+}
+
+func FromContext(ctx context.Context) Actor {
+	return Actor{
+		UserId:   middleware.UserIDFromContext(ctx),
+		ApiKeyId: middleware.APIKeyIDFromContext(ctx),
+		Role:     domain.Role(middleware.RoleFromContext(ctx)),
+	}
+}
+
+func (actor Actor) IsAdmin() bool {
+	return (actor.UserId != "" || actor.ApiKeyId != "") && actor.Role == domain.RoleAdmin
+}
+
+func (actor Actor) Is(userId string) bool {
+	return actor.UserId != "" && actor.UserId == userId
+}
+
+// isAdminUser leaves out api keys, which act as admins inside their scopes but
+// can't hand out roles, manage keys or touch deleted records.
+func (actor Actor) isAdminUser() bool {
+	return actor.IsAdmin() && actor.UserId != ""
+}
+
+// CanAssignRole lets anyone sign up as a student, every other role is handed
+// out by admin users.
+func CanAssignRole(actor Actor, role domain.Role) error {
+	if role == domain.RoleStudent || actor.isAdminUser() {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// CanChangeRole keeps changing the role of an existing user, promotions and
+// demotions alike, with admin users.
+func CanChangeRole(actor Actor) error {
+	if actor.isAdminUser() {
+		return nil
+	}
+	return ErrForbidden
+}
+
+func CanListUsers(actor Actor) error {
+	if actor.IsAdmin() || actor.Role == domain.RoleInstructor {
+		return nil
+	}
+	return ErrForbidden
+}
+
+func CanReadUser(actor Actor, userId string) error {
+	if actor.Is(userId) || CanListUsers(actor) == nil {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// CanUpdateUser lets users edit themselves and admins edit anyone. Api keys
+// can't touch admin users or change anyone's email, which would let them take
+// the account over through a password reset.
+func CanUpdateUser(actor Actor, user *domain.User, changesEmail bool) error {
+	if actor.Is(user.Id) || actor.isAdminUser() {
+		return nil
+	}
+	if actor.IsAdmin() && user.Role != domain.RoleAdmin && !changesEmail {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// CanSetPassword only lets users change their own password, since doing it
+// requires the current one.
+func CanSetPassword(actor Actor, userId string) error {
+	if actor.Is(userId) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// CanDeleteUser leaves deleting admin users to admin users.
+func CanDeleteUser(actor Actor, user *domain.User) error {
+	if actor.isAdminUser() {
+		return nil
+	}
+	if actor.IsAdmin() && user.Role != domain.RoleAdmin {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// CanManageAPIKeys keeps key management with admin users, keys can't mint
+// other keys.
+func CanManageAPIKeys(actor Actor) error {
+	if actor.isAdminUser() {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// CanManageDeleted covers listing, restoring and permanently deleting soft
+// deleted records.
+func CanManageDeleted(actor Actor) error {
+	if actor.isAdminUser() {
+		return nil
+	}
+	return ErrForbidden
+}
+
+func CanCreateCourse(actor Actor) error {
+	if actor.IsAdmin() {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// CanUpdateCourse lets instructors edit the courses they teach. Reassigning
+// the instructor is left to admins.
+func CanUpdateCourse(actor Actor, course *domain.Course, changesInstructor bool) error {
+	if actor.IsAdmin() {
+		return nil
+	}
+	if !changesInstructor && teaches(actor, course) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+func CanDeleteCourse(actor Actor) error {
+	if actor.IsAdmin() {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// CanEnroll lets users enroll themselves, admins can enroll anyone.
+func CanEnroll(actor Actor, userId string) error {
+	if actor.Is(userId) || actor.IsAdmin() {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// CanListAllEnrollments reports whether the caller may see enrollments of
+// other users, students are limited to their own.
+func CanListAllEnrollments(actor Actor) bool {
+	return actor.IsAdmin() || actor.Role == domain.RoleInstructor
+}
+
+func CanReadEnrollment(actor Actor, enrollment *domain.Enrollment) error {
+	if actor.Is(enrollment.UserId) || CanListAllEnrollments(actor) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// CanUpdateEnrollment lets admins and the course's instructor move an
+// enrollment to any status, while students can only drop their own.
+func CanUpdateEnrollment(actor Actor, enrollment *domain.Enrollment, course *domain.Course, next domain.EnrollmentStatus) error {
+	if actor.IsAdmin() || teaches(actor, course) {
+		return nil
+	}
+	if actor.Is(enrollment.UserId) && next == domain.EnrollmentDropped {
+		return nil
+	}
+	return ErrForbidden
+}
+
+func CanDeleteEnrollment(actor Actor, course *domain.Course) error {
+	if actor.IsAdmin() || teaches(actor, course) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+func teaches(actor Actor, course *domain.Course) bool {
+	return actor.Role == domain.RoleInstructor && course.InstructorId != nil && actor.Is(*course.InstructorId)
+}
@@ -0,0 +1,188 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/zchelalo/rest-api-go/internal/domain"
+	"github.com/zchelalo/rest-api-go/pkg/middleware"
+)
+
+var (
+	anonymous  = Actor{}
+	admin      = Actor{UserId: "admin", Role: domain.RoleAdmin}
+	instructor = Actor{UserId: "instructor", Role: domain.RoleInstructor}
+	student    = Actor{UserId: "student", Role: domain.RoleStudent}
+	apiKey     = Actor{ApiKeyId: "key", Role: domain.RoleAdmin}
+)
+
+func check(t *testing.T, name string, err error, allowed bool) {
+	t.Helper()
+	if allowed && err != nil {
+		t.Errorf("%s: got %v, want allowed", name, err)
+	}
+	if !allowed && !errors.Is(err, ErrForbidden) {
+		t.Errorf("%s: got %v, want ErrForbidden", name, err)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want Actor
+	}{
+		{"anonymous", context.Background(), anonymous},
+		{"user", middleware.WithUser(context.Background(), "student", "student"), student},
+		{"api key", middleware.WithAPIKey(context.Background(), "key", "admin"), apiKey},
+	}
+
+	for _, test := range tests {
+		if got := FromContext(test.ctx); got != test.want {
+			t.Errorf("%s: FromContext() = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		want  bool
+	}{
+		{"admin", admin, true},
+		{"api key", apiKey, true},
+		{"role without identity", Actor{Role: domain.RoleAdmin}, false},
+		{"instructor", instructor, false},
+		{"anonymous", anonymous, false},
+	}
+
+	for _, test := range tests {
+		if got := test.actor.IsAdmin(); got != test.want {
+			t.Errorf("%s: IsAdmin() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestUserPolicies(t *testing.T) {
+	studentUser := &domain.User{Id: "student", Role: domain.RoleStudent}
+	otherAdmin := &domain.User{Id: "other-admin", Role: domain.RoleAdmin}
+
+	tests := []struct {
+		name    string
+		err     error
+		allowed bool
+	}{
+		{"anyone signs up as student", CanAssignRole(anonymous, domain.RoleStudent), true},
+		{"anonymous can't pick instructor", CanAssignRole(anonymous, domain.RoleInstructor), false},
+		{"admin assigns admin", CanAssignRole(admin, domain.RoleAdmin), true},
+		{"api key can't assign admin", CanAssignRole(apiKey, domain.RoleAdmin), false},
+		{"admin changes roles", CanChangeRole(admin), true},
+		{"api key can't change roles", CanChangeRole(apiKey), false},
+		{"student can't change roles", CanChangeRole(student), false},
+		{"instructor lists users", CanListUsers(instructor), true},
+		{"student can't list users", CanListUsers(student), false},
+		{"student reads itself", CanReadUser(student, "student"), true},
+		{"student can't read others", CanReadUser(student, "other"), false},
+		{"student updates itself", CanUpdateUser(student, studentUser, true), true},
+		{"instructor can't update others", CanUpdateUser(instructor, studentUser, false), false},
+		{"admin updates others", CanUpdateUser(admin, studentUser, true), true},
+		{"admin updates other admins", CanUpdateUser(admin, otherAdmin, true), true},
+		{"api key updates students", CanUpdateUser(apiKey, studentUser, false), true},
+		{"api key can't change emails", CanUpdateUser(apiKey, studentUser, true), false},
+		{"api key can't update admins", CanUpdateUser(apiKey, otherAdmin, false), false},
+		{"user sets own password", CanSetPassword(student, "student"), true},
+		{"admin can't set others password", CanSetPassword(admin, "student"), false},
+		{"admin deletes users", CanDeleteUser(admin, studentUser), true},
+		{"admin deletes admins", CanDeleteUser(admin, otherAdmin), true},
+		{"api key deletes students", CanDeleteUser(apiKey, studentUser), true},
+		{"api key can't delete admins", CanDeleteUser(apiKey, otherAdmin), false},
+		{"student can't delete users", CanDeleteUser(student, studentUser), false},
+		{"admin manages api keys", CanManageAPIKeys(admin), true},
+		{"api key can't manage api keys", CanManageAPIKeys(apiKey), false},
+		{"admin manages deleted", CanManageDeleted(admin), true},
+		{"api key can't manage deleted", CanManageDeleted(apiKey), false},
+		{"instructor can't manage deleted", CanManageDeleted(instructor), false},
+	}
+
+	for _, test := range tests {
+		check(t, test.name, test.err, test.allowed)
+	}
+}
+
+func TestCoursePolicies(t *testing.T) {
+	instructorId := "instructor"
+	taught := &domain.Course{Id: "taught", InstructorId: &instructorId}
+	other := &domain.Course{Id: "other"}
+
+	tests := []struct {
+		name    string
+		err     error
+		allowed bool
+	}{
+		{"admin creates courses", CanCreateCourse(admin), true},
+		{"instructor can't create courses", CanCreateCourse(instructor), false},
+		{"instructor updates its course", CanUpdateCourse(instructor, taught, false), true},
+		{"instructor can't reassign its course", CanUpdateCourse(instructor, taught, true), false},
+		{"instructor can't update other courses", CanUpdateCourse(instructor, other, false), false},
+		{"student can't update courses", CanUpdateCourse(Actor{UserId: "instructor", Role: domain.RoleStudent}, taught, false), false},
+		{"admin reassigns courses", CanUpdateCourse(admin, other, true), true},
+		{"admin deletes courses", CanDeleteCourse(admin), true},
+		{"instructor can't delete courses", CanDeleteCourse(instructor), false},
+	}
+
+	for _, test := range tests {
+		check(t, test.name, test.err, test.allowed)
+	}
+}
+
+func TestEnrollmentPolicies(t *testing.T) {
+	instructorId := "instructor"
+	taught := &domain.Course{Id: "taught", InstructorId: &instructorId}
+	other := &domain.Course{Id: "other"}
+	own := &domain.Enrollment{UserId: "student", CourseId: "taught"}
+
+	tests := []struct {
+		name    string
+		err     error
+		allowed bool
+	}{
+		{"student enrolls itself", CanEnroll(student, "student"), true},
+		{"student can't enroll others", CanEnroll(student, "other"), false},
+		{"admin enrolls others", CanEnroll(admin, "student"), true},
+		{"student reads own enrollment", CanReadEnrollment(student, own), true},
+		{"other student can't read it", CanReadEnrollment(Actor{UserId: "other", Role: domain.RoleStudent}, own), false},
+		{"instructor reads enrollments", CanReadEnrollment(instructor, own), true},
+		{"student drops own enrollment", CanUpdateEnrollment(student, own, taught, domain.EnrollmentDropped), true},
+		{"student can't activate own enrollment", CanUpdateEnrollment(student, own, taught, domain.EnrollmentActive), false},
+		{"instructor activates in its course", CanUpdateEnrollment(instructor, own, taught, domain.EnrollmentActive), true},
+		{"instructor can't update in other courses", CanUpdateEnrollment(instructor, own, other, domain.EnrollmentActive), false},
+		{"instructor deletes in its course", CanDeleteEnrollment(instructor, taught), true},
+		{"student can't delete enrollments", CanDeleteEnrollment(student, taught), false},
+		{"admin deletes enrollments", CanDeleteEnrollment(admin, other), true},
+	}
+
+	for _, test := range tests {
+		check(t, test.name, test.err, test.allowed)
+	}
+}
+
+func TestCanListAllEnrollments(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		want  bool
+	}{
+		{"admin", admin, true},
+		{"instructor", instructor, true},
+		{"student", student, false},
+		{"anonymous", anonymous, false},
+	}
+
+	for _, test := range tests {
+		if got := CanListAllEnrollments(test.actor); got != test.want {
+			t.Errorf("%s: CanListAllEnrollments() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
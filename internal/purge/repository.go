@@ -0,0 +1,75 @@
+package purge
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/zchelalo/rest-api-go/internal/domain"
+	"gorm.io/gorm"
+)
+
+type (
+	// Result holds how many rows of each table a purge deleted.
+	Result struct {
+		Enrollments int64
+		Courses     int64
+		Users       int64
+	}
+
+	Repository interface {
+		DeleteBefore(ctx context.Context, before time.Time) (Result, error)
+	}
+
+	repository struct {
+		log *slog.Logger
+		db  *gorm.DB
+	}
+)
+
+func NewRepository(log *slog.Logger, db *gorm.DB) Repository {
+	return &repository{
+		log: log,
+		db:  db,
+	}
+}
+
+// DeleteBefore permanently deletes the rows soft deleted before the given
+// time, enrollments first since they reference both users and courses. The
+// enrollments of a purged user or course go with it even when they are live,
+// which deleting a user or course leaves them as, so they are deleted and
+// counted here instead of silently by the foreign keys.
+func (repo *repository) DeleteBefore(ctx context.Context, before time.Time) (Result, error) {
+	var result Result
+
+	err := repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		courses := tx.Unscoped().Model(&domain.Course{}).Select("id").Where("deleted_at < ?", before)
+		users := tx.Unscoped().Model(&domain.User{}).Select("id").Where("deleted_at < ?", before)
+
+		deleted := tx.Unscoped().Where("deleted_at < ? OR course_id IN (?) OR user_id IN (?)", before, courses, users).Delete(&domain.Enrollment{})
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		result.Enrollments = deleted.RowsAffected
+
+		deleted = tx.Unscoped().Where("deleted_at < ?", before).Delete(&domain.Course{})
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		result.Courses = deleted.RowsAffected
+
+		deleted = tx.Unscoped().Where("deleted_at < ?", before).Delete(&domain.User{})
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		result.Users = deleted.RowsAffected
+
+		return nil
+	})
+	if err != nil {
+		repo.log.ErrorContext(ctx, "purge deleted rows", "error", err, "before", before)
+		return Result{}, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,59 @@
+package purge
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type (
+	Service interface {
+		Run(ctx context.Context)
+		Purge(ctx context.Context) (Result, error)
+	}
+
+	service struct {
+		log        *slog.Logger
+		repository Repository
+		retention  time.Duration
+		interval   time.Duration
+	}
+)
+
+func NewService(log *slog.Logger, repo Repository, retention, interval time.Duration) Service {
+	return &service{
+		log:        log,
+		repository: repo,
+		retention:  retention,
+		interval:   interval,
+	}
+}
+
+// Run purges once right away and then every interval until ctx is done.
+func (srv *service) Run(ctx context.Context) {
+	ticker := time.NewTicker(srv.interval)
+	defer ticker.Stop()
+
+	for {
+		// failures are logged by the repository and retried on the next tick
+		_, _ = srv.Purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (srv *service) Purge(ctx context.Context) (Result, error) {
+	before := time.Now().Add(-srv.retention)
+
+	result, err := srv.repository.DeleteBefore(ctx, before)
+	if err != nil {
+		return Result{}, err
+	}
+
+	srv.log.InfoContext(ctx, "purged deleted rows", "before", before, "enrollments", result.Enrollments, "courses", result.Courses, "users", result.Users)
+	return result, nil
+}
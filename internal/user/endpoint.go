@@ -6,56 +6,72 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/zchelalo/rest-api-go/internal/domain"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
 	"github.com/zchelalo/rest-api-go/pkg/meta"
-)
-
-type status string
-
-const (
-	statusSuccess status = "success"
-	statusError   status = "error"
+	"github.com/zchelalo/rest-api-go/pkg/response"
+	"github.com/zchelalo/rest-api-go/pkg/validator"
 )
 
 type (
 	Controller func(w http.ResponseWriter, req *http.Request)
 
+	Config struct {
+		LimPageDef int
+	}
+
 	Endpoints struct {
-		Create Controller
-		Get    Controller
-		GetAll Controller
-		Update Controller
-		Delete Controller
+		Create              Controller
+		Get                 Controller
+		GetAll              Controller
+		Update              Controller
+		SetPassword         Controller
+		RequestVerification Controller
+		Verify              Controller
+		Delete              Controller
+		Restore             Controller
+		DeletePermanently   Controller
 	}
 
 	CreateRequest struct {
-		FirstName string `json:"first_name"`
-		LastName  string `json:"last_name"`
-		Email     string `json:"email"`
-		Phone     string `json:"phone"`
+		FirstName string  `json:"first_name" validate:"required,max=100"`
+		LastName  string  `json:"last_name" validate:"required,max=100"`
+		Email     string  `json:"email" validate:"required,max=100,email"`
+		Phone     string  `json:"phone" validate:"max=30,phone"`
+		Password  string  `json:"password"`
+		Role      *string `json:"role" validate:"oneof=admin instructor student"`
 	}
 
 	UpdateRequest struct {
-		FirstName *string `json:"first_name"`
-		LastName  *string `json:"last_name"`
-		Email     *string `json:"email"`
-		Phone     *string `json:"phone"`
+		FirstName *string `json:"first_name" validate:"required,max=100"`
+		LastName  *string `json:"last_name" validate:"required,max=100"`
+		Email     *string `json:"email" validate:"required,max=100,email"`
+		Phone     *string `json:"phone" validate:"max=30,phone"`
+		Role      *string `json:"role" validate:"required,oneof=admin instructor student"`
 	}
 
-	Response struct {
-		Status status      `json:"status"`
-		Data   interface{} `json:"data,omitempty"`
-		Error  string      `json:"error,omitempty"`
-		Meta   *meta.Meta  `json:"meta,omitempty"`
+	SetPasswordRequest struct {
+		CurrentPassword *string `json:"current_password"`
+		Password        string  `json:"password" validate:"required"`
+	}
+
+	VerifyRequest struct {
+		Token string `json:"token" validate:"required"`
 	}
 )
 
-func MakeEndpoints(service Service) Endpoints {
+func MakeEndpoints(service Service, config Config) Endpoints {
 	return Endpoints{
-		Create: makeCreateEndpoint(service),
-		Get:    makeGetEndpoint(service),
-		GetAll: makeGetAllEndpoint(service),
-		Update: makeUpdateEndpoint(service),
-		Delete: makeDeleteEndpoint(service),
+		Create:              makeCreateEndpoint(service),
+		Get:                 makeGetEndpoint(service),
+		GetAll:              makeGetAllEndpoint(service, config),
+		Update:              makeUpdateEndpoint(service),
+		SetPassword:         makeSetPasswordEndpoint(service),
+		RequestVerification: makeRequestVerificationEndpoint(service),
+		Verify:              makeVerifyEndpoint(service),
+		Delete:              makeDeleteEndpoint(service),
+		Restore:             makeRestoreEndpoint(service),
+		DeletePermanently:   makeDeletePermanentlyEndpoint(service),
 	}
 }
 
@@ -63,51 +79,22 @@ func makeCreateEndpoint(service Service) Controller {
 	return func(w http.ResponseWriter, req *http.Request) {
 		var request CreateRequest
 		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			// json.NewEncoder(w).Encode(ErrorResponse{
-			// 	Error: err.Error(),
-			// })
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  fmt.Sprintf("Invalid request format, %v", err.Error()),
-			})
-			return
-		}
-
-		if request.FirstName == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  "First name is required",
-			})
+			response.Error(w, apperr.BadRequest("invalid_request", fmt.Sprintf("Invalid request format, %v", err.Error())))
 			return
 		}
 
-		if request.LastName == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  "Last name is required",
-			})
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, err)
 			return
 		}
 
-		user, err := service.Create(request.FirstName, request.LastName, request.Email, request.Phone)
+		user, err := service.Create(req.Context(), request.FirstName, request.LastName, request.Email, request.Phone, request.Password, request.Role)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  err.Error(),
-			})
+			response.Error(w, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
-
-		json.NewEncoder(w).Encode(&Response{
-			Status: statusSuccess,
-			Data:   user,
-		})
+		response.Created(w, user)
 	}
 }
 
@@ -115,128 +102,128 @@ func makeGetEndpoint(service Service) Controller {
 	return func(w http.ResponseWriter, req *http.Request) {
 		id := req.PathValue("id")
 
-		user, err := service.Get(id)
+		user, err := service.Get(req.Context(), id)
 		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  err.Error(),
-			})
+			response.Error(w, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-
-		// json.NewEncoder(w).Encode(map[string]string{
-		// 	"payload": response,
-		// })
-		json.NewEncoder(w).Encode(&Response{
-			Status: statusSuccess,
-			Data:   user,
-		})
+		response.OK(w, user, nil)
 	}
 }
 
-func makeGetAllEndpoint(service Service) Controller {
+func makeGetAllEndpoint(service Service, config Config) Controller {
 	return func(w http.ResponseWriter, req *http.Request) {
 		queries := req.URL.Query()
 		filters := Filters{
 			FirstName: queries.Get("first_name"),
 			LastName:  queries.Get("last_name"),
+			Deleted:   domain.DeletedFilter(queries.Get("deleted")),
 		}
 
 		limit, _ := strconv.Atoi(queries.Get("limit"))
 		page, _ := strconv.Atoi(queries.Get("page"))
 
-		count, err := service.Count(filters)
+		count, err := service.Count(req.Context(), filters)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  err.Error(),
-			})
+			response.Error(w, err)
 			return
 		}
-		meta, err := meta.New(page, limit, count)
+		meta := meta.New(page, limit, count, config.LimPageDef)
+
+		users, err := service.GetAll(req.Context(), filters, meta.Offset(), meta.Limit())
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  err.Error(),
-			})
+			response.Error(w, err)
 			return
 		}
 
-		users, err := service.GetAll(filters, meta.Offset(), meta.Limit())
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  err.Error(),
-			})
+		response.OK(w, users, meta)
+	}
+}
+
+func makeUpdateEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id := req.PathValue("id")
+
+		var request UpdateRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			response.Error(w, apperr.BadRequest("invalid_request", fmt.Sprintf("Invalid request format, %v", err.Error())))
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, err)
+			return
+		}
 
-		// json.NewEncoder(w).Encode(map[string]string{
-		// 	"payload": response,
-		// })
-		json.NewEncoder(w).Encode(&Response{
-			Status: statusSuccess,
-			Data:   users,
-			Meta:   meta,
-		})
+		user, err := service.Update(req.Context(), id, request.FirstName, request.LastName, request.Email, request.Phone, request.Role)
+		if err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		response.OK(w, user, nil)
 	}
 }
 
-func makeUpdateEndpoint(service Service) Controller {
+func makeSetPasswordEndpoint(service Service) Controller {
 	return func(w http.ResponseWriter, req *http.Request) {
 		id := req.PathValue("id")
 
-		var request UpdateRequest
+		var request SetPasswordRequest
 		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  fmt.Sprintf("Invalid request format, %v", err.Error()),
-			})
+			response.Error(w, apperr.BadRequest("invalid_request", fmt.Sprintf("Invalid request format, %v", err.Error())))
+			return
+		}
+
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		if err := service.SetPassword(req.Context(), id, request.CurrentPassword, request.Password); err != nil {
+			response.Error(w, err)
 			return
 		}
 
-		if request.FirstName != nil && *request.FirstName == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  "First name is required",
-			})
+		response.OK(w, "Password updated successfully", nil)
+	}
+}
+
+func makeRequestVerificationEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id := req.PathValue("id")
+
+		if err := service.RequestVerification(req.Context(), id); err != nil {
+			response.Error(w, err)
 			return
 		}
 
-		if request.LastName != nil && *request.LastName == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  "Last name is required",
-			})
+		response.Accepted(w, "Verification email sent")
+	}
+}
+
+func makeVerifyEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id := req.PathValue("id")
+
+		var request VerifyRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			response.Error(w, apperr.BadRequest("invalid_request", fmt.Sprintf("Invalid request format, %v", err.Error())))
 			return
 		}
 
-		if err := service.Update(id, request.FirstName, request.LastName, request.Email, request.Phone); err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  "User doesn't exist",
-			})
+		if err := validator.Struct(request); err != nil {
+			response.Error(w, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		if err := service.VerifyEmail(req.Context(), id, request.Token); err != nil {
+			response.Error(w, err)
+			return
+		}
 
-		json.NewEncoder(w).Encode(&Response{
-			Status: statusSuccess,
-			Data:   "User updated successfully",
-		})
+		response.OK(w, "Email verified successfully", nil)
 	}
 }
 
@@ -244,21 +231,39 @@ func makeDeleteEndpoint(service Service) Controller {
 	return func(w http.ResponseWriter, req *http.Request) {
 		id := req.PathValue("id")
 
-		err := service.Delete(id)
+		err := service.Delete(req.Context(), id)
 		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(&Response{
-				Status: statusError,
-				Error:  err.Error(),
-			})
+			response.Error(w, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		response.OK(w, "User deleted successfully", nil)
+	}
+}
+
+func makeRestoreEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id := req.PathValue("id")
+
+		user, err := service.Restore(req.Context(), id)
+		if err != nil {
+			response.Error(w, err)
+			return
+		}
+
+		response.OK(w, user, nil)
+	}
+}
+
+func makeDeletePermanentlyEndpoint(service Service) Controller {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id := req.PathValue("id")
+
+		if err := service.DeletePermanently(req.Context(), id); err != nil {
+			response.Error(w, err)
+			return
+		}
 
-		json.NewEncoder(w).Encode(&Response{
-			Status: statusSuccess,
-			Data:   "User deleted successfully",
-		})
+		response.OK(w, "User deleted permanently", nil)
 	}
 }
@@ -1,76 +1,108 @@
 package user
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/zchelalo/rest-api-go/internal/domain"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"github.com/zchelalo/rest-api-go/pkg/database"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type (
 	Repository interface {
-		Create(user *domain.User) error
-		GetAll(filters Filters, offset, limit int) ([]domain.User, error)
-		Get(id string) (*domain.User, error)
-		Update(id string, firstName, lastName, email, phone *string) error
-		Delete(id string) error
-		Count(filters Filters) (int, error)
+		Create(ctx context.Context, user *domain.User) error
+		GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.User, error)
+		Get(ctx context.Context, id string) (*domain.User, error)
+		GetByEmail(ctx context.Context, email string) (*domain.User, error)
+		Update(ctx context.Context, id string, firstName, lastName, email, phone *string, role *domain.Role) error
+		UpdatePassword(ctx context.Context, id, passwordHash string) error
+		Delete(ctx context.Context, id string) error
+		Restore(ctx context.Context, id string) error
+		DeletePermanently(ctx context.Context, id string) error
+		Count(ctx context.Context, filters Filters) (int, error)
+		Transaction(ctx context.Context, fn func(repo Repository) error) error
+		WithTx(ctx context.Context) context.Context
+		CreateToken(ctx context.Context, token *domain.UserToken) error
+		InvalidateTokens(ctx context.Context, userId string, purpose domain.TokenPurpose) error
+		LastTokenIssuedAt(ctx context.Context, userId string, purpose domain.TokenPurpose) (*time.Time, error)
+		GetActiveToken(ctx context.Context, purpose domain.TokenPurpose, tokenHash string, now time.Time) (*domain.UserToken, error)
+		UseToken(ctx context.Context, userId string, purpose domain.TokenPurpose, tokenHash string, now time.Time) error
+		MarkEmailVerified(ctx context.Context, id string, verifiedAt time.Time) error
 	}
 
 	repository struct {
-		log *log.Logger
+		log *slog.Logger
 		db  *gorm.DB
 	}
 )
 
-func NewRepository(log *log.Logger, db *gorm.DB) Repository {
+func NewRepository(log *slog.Logger, db *gorm.DB) Repository {
 	return &repository{
 		log: log,
 		db:  db,
 	}
 }
 
-func (repo *repository) Create(user *domain.User) error {
-	if err := repo.db.Create(user).Error; err != nil {
-		repo.log.Println(err)
-		return err
+func (repo *repository) Create(ctx context.Context, user *domain.User) error {
+	if err := repo.db.WithContext(ctx).Create(user).Error; err != nil {
+		repo.log.ErrorContext(ctx, "create user", "error", err, "id", user.Id)
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrEmailTaken
+		}
+		return apperr.FromDB(err, "user")
 	}
 
-	repo.log.Println("user created with id: ", user.Id)
+	repo.log.InfoContext(ctx, "user created", "id", user.Id)
 	return nil
 }
 
-func (repo *repository) GetAll(filters Filters, offset, limit int) ([]domain.User, error) {
+func (repo *repository) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.User, error) {
 	var users []domain.User
 
-	tx := repo.db.Model(&users)
+	tx := repo.db.WithContext(ctx).Model(&users)
 	tx = applyFilters(tx, filters)
 	tx = tx.Limit(limit).Offset(offset)
-	// if err := repo.db.Model(&users).Select("id, first_name, email, created_at").Order("created_at desc").Find(&users).Error; err != nil {
+	// if err := repo.db.WithContext(ctx).Model(&users).Select("id, first_name, email, created_at").Order("created_at desc").Find(&users).Error; err != nil {
 	if err := tx.Order("created_at desc").Find(&users).Error; err != nil {
-		repo.log.Println(err)
-		return nil, err
+		repo.log.ErrorContext(ctx, "get all users", "error", err)
+		return nil, apperr.FromDB(err, "user")
 	}
 
 	return users, nil
 }
 
-func (repo *repository) Get(id string) (*domain.User, error) {
+func (repo *repository) Get(ctx context.Context, id string) (*domain.User, error) {
 	user := domain.User{
 		Id: id,
 	}
 
-	if err := repo.db.Model(&user).First(&user).Error; err != nil {
-		repo.log.Println(err)
-		return nil, err
+	if err := repo.db.WithContext(ctx).Model(&user).First(&user).Error; err != nil {
+		repo.log.ErrorContext(ctx, "get user", "error", err, "id", id)
+		return nil, apperr.FromDB(err, "user")
+	}
+
+	return &user, nil
+}
+
+func (repo *repository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	var user domain.User
+
+	if err := repo.db.WithContext(ctx).Where("lower(email) = ?", email).First(&user).Error; err != nil {
+		repo.log.ErrorContext(ctx, "get user by email", "error", err)
+		return nil, apperr.FromDB(err, "user")
 	}
 
 	return &user, nil
 }
 
-func (repo *repository) Update(id string, firstName, lastName, email, phone *string) error {
+func (repo *repository) Update(ctx context.Context, id string, firstName, lastName, email, phone *string, role *domain.Role) error {
 	values := make(map[string]interface{})
 
 	if firstName != nil {
@@ -83,45 +115,228 @@ func (repo *repository) Update(id string, firstName, lastName, email, phone *str
 
 	if email != nil {
 		values["email"] = *email
+		// a new address has to be verified again
+		values["email_verified_at"] = nil
 	}
 
 	if phone != nil {
 		values["phone"] = *phone
 	}
 
-	if err := repo.db.Model(&domain.User{}).Where("id = ?", id).Updates(values).Error; err != nil {
-		repo.log.Println(err)
+	if role != nil {
+		values["role"] = *role
+	}
+
+	if len(values) == 0 {
+		_, err := repo.Get(ctx, id)
 		return err
 	}
 
+	result := repo.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id).Updates(values)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "update user", "error", result.Error, "id", id)
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return ErrEmailTaken
+		}
+		return apperr.FromDB(result.Error, "user")
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.FromDB(gorm.ErrRecordNotFound, "user")
+	}
+
+	return nil
+}
+
+func (repo *repository) UpdatePassword(ctx context.Context, id, passwordHash string) error {
+	result := repo.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id).Update("password_hash", passwordHash)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "update user password", "error", result.Error, "id", id)
+		return apperr.FromDB(result.Error, "user")
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.FromDB(gorm.ErrRecordNotFound, "user")
+	}
+
 	return nil
 }
 
-func (repo *repository) Delete(id string) error {
+func (repo *repository) Delete(ctx context.Context, id string) error {
 	user := domain.User{
 		Id: id,
 	}
 
-	if err := repo.db.Model(&user).Delete(&user).Error; err != nil {
-		repo.log.Println(err)
-		return err
+	result := repo.db.WithContext(ctx).Model(&user).Delete(&user)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "delete user", "error", result.Error, "id", id)
+		return apperr.FromDB(result.Error, "user")
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.FromDB(gorm.ErrRecordNotFound, "user")
+	}
+
+	return nil
+}
+
+func (repo *repository) Restore(ctx context.Context, id string) error {
+	result := repo.db.WithContext(ctx).Unscoped().Model(&domain.User{}).Where("id = ? AND deleted_at IS NOT NULL", id).Update("deleted_at", nil)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "restore user", "error", result.Error, "id", id)
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return ErrEmailTaken
+		}
+		return apperr.FromDB(result.Error, "user")
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("deleted_user_not_found", "deleted user not found")
 	}
 
+	repo.log.InfoContext(ctx, "user restored", "id", id)
 	return nil
 }
 
-func (repo *repository) Count(filters Filters) (int, error) {
+// DeletePermanently only removes users that were soft deleted first, so live
+// ones always go through Delete and its rules.
+func (repo *repository) DeletePermanently(ctx context.Context, id string) error {
+	result := repo.db.WithContext(ctx).Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).Delete(&domain.User{})
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "delete user permanently", "error", result.Error, "id", id)
+		return apperr.FromDB(result.Error, "user")
+	}
+
+	if result.RowsAffected == 0 {
+		if _, err := repo.Get(ctx, id); err == nil {
+			return apperr.Conflict("user_not_deleted", "user has to be deleted before it can be deleted permanently")
+		}
+		return apperr.NotFound("deleted_user_not_found", "deleted user not found")
+	}
+
+	repo.log.InfoContext(ctx, "user deleted permanently", "id", id)
+	return nil
+}
+
+func (repo *repository) Count(ctx context.Context, filters Filters) (int, error) {
 	var count int64
-	tx := repo.db.Model(&domain.User{})
+	tx := repo.db.WithContext(ctx).Model(&domain.User{})
 	tx = applyFilters(tx, filters)
 	if err := tx.Count(&count).Error; err != nil {
-		return 0, err
+		repo.log.ErrorContext(ctx, "count users", "error", err)
+		return 0, apperr.FromDB(err, "user")
 	}
 
 	return int(count), nil
 }
 
+// Transaction joins the transaction carried by ctx, if any.
+func (repo *repository) Transaction(ctx context.Context, fn func(repo Repository) error) error {
+	return database.Conn(ctx, repo.db).Transaction(func(tx *gorm.DB) error {
+		return fn(&repository{
+			log: repo.log,
+			db:  tx,
+		})
+	})
+}
+
+// WithTx returns ctx carrying the transaction the repository runs in, so
+// repositories of other packages handed ctx join it.
+func (repo *repository) WithTx(ctx context.Context) context.Context {
+	return database.WithTx(ctx, repo.db)
+}
+
+func (repo *repository) CreateToken(ctx context.Context, token *domain.UserToken) error {
+	if err := repo.db.WithContext(ctx).Create(token).Error; err != nil {
+		repo.log.ErrorContext(ctx, "create user token", "error", err, "user_id", token.UserId, "purpose", token.Purpose)
+		return apperr.FromDB(err, "user_token")
+	}
+
+	return nil
+}
+
+// InvalidateTokens marks every unused token of purpose as used so only the
+// most recently issued one works.
+func (repo *repository) InvalidateTokens(ctx context.Context, userId string, purpose domain.TokenPurpose) error {
+	tx := repo.db.WithContext(ctx).Model(&domain.UserToken{}).Where("user_id = ? AND purpose = ? AND used_at IS NULL", userId, purpose)
+	if err := tx.Update("used_at", time.Now()).Error; err != nil {
+		repo.log.ErrorContext(ctx, "invalidate user tokens", "error", err, "user_id", userId, "purpose", purpose)
+		return apperr.FromDB(err, "user_token")
+	}
+
+	return nil
+}
+
+// LastTokenIssuedAt returns when the latest token of purpose was issued to the
+// user, or nil when there is none.
+func (repo *repository) LastTokenIssuedAt(ctx context.Context, userId string, purpose domain.TokenPurpose) (*time.Time, error) {
+	var tokens []domain.UserToken
+
+	tx := repo.db.WithContext(ctx).Where("user_id = ? AND purpose = ?", userId, purpose).Order("created_at desc").Limit(1)
+	if err := tx.Find(&tokens).Error; err != nil {
+		repo.log.ErrorContext(ctx, "get last user token", "error", err, "user_id", userId, "purpose", purpose)
+		return nil, apperr.FromDB(err, "user_token")
+	}
+
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	return tokens[0].CreatedAt, nil
+}
+
+// GetActiveToken locks an unused, unexpired token. Anything else returns
+// ErrInvalidToken.
+func (repo *repository) GetActiveToken(ctx context.Context, purpose domain.TokenPurpose, tokenHash string, now time.Time) (*domain.UserToken, error) {
+	var token domain.UserToken
+
+	tx := repo.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", purpose, tokenHash, now)
+	if err := tx.First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		repo.log.ErrorContext(ctx, "get user token", "error", err, "purpose", purpose)
+		return nil, apperr.FromDB(err, "user_token")
+	}
+
+	return &token, nil
+}
+
+// UseToken consumes a token in a single statement so it can't be used twice
+// concurrently. Unknown, expired and used tokens return ErrInvalidToken.
+func (repo *repository) UseToken(ctx context.Context, userId string, purpose domain.TokenPurpose, tokenHash string, now time.Time) error {
+	result := repo.db.WithContext(ctx).Model(&domain.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", userId, purpose, tokenHash, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "use user token", "error", result.Error, "user_id", userId, "purpose", purpose)
+		return apperr.FromDB(result.Error, "user_token")
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+func (repo *repository) MarkEmailVerified(ctx context.Context, id string, verifiedAt time.Time) error {
+	result := repo.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id).Update("email_verified_at", verifiedAt)
+	if result.Error != nil {
+		repo.log.ErrorContext(ctx, "mark user email verified", "error", result.Error, "id", id)
+		return apperr.FromDB(result.Error, "user")
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.FromDB(gorm.ErrRecordNotFound, "user")
+	}
+
+	return nil
+}
+
 func applyFilters(tx *gorm.DB, filters Filters) *gorm.DB {
+	tx = filters.Deleted.Apply(tx)
+
 	if filters.FirstName != "" {
 		filters.FirstName = fmt.Sprintf("%%%s%%", strings.ToLower(filters.FirstName))
 		tx = tx.Where("lower(first_name) like ?", filters.FirstName)
@@ -1,85 +1,449 @@
 package user
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/zchelalo/rest-api-go/internal/domain"
+	"github.com/zchelalo/rest-api-go/internal/policy"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"github.com/zchelalo/rest-api-go/pkg/background"
+	"github.com/zchelalo/rest-api-go/pkg/mail"
+	"github.com/zchelalo/rest-api-go/pkg/token"
+)
+
+// passwordResetInterval is the least time between two reset emails to the same
+// user, more only flood the inbox.
+const passwordResetInterval = time.Minute
+
+var (
+	ErrEmailTaken           = apperr.Conflict("email_already_exists", "a user with this email already exists")
+	ErrEmailAlreadyVerified = apperr.Conflict("email_already_verified", "email is already verified")
+	ErrInvalidToken         = apperr.BadRequest("invalid_token", "token is invalid, expired or already used")
 )
 
 type (
 	Filters struct {
 		FirstName string
 		LastName  string
+		Deleted   domain.DeletedFilter
 	}
 
 	Service interface {
-		Create(firstName, lastName, email, phone string) (*domain.User, error)
-		GetAll(filters Filters, offset, limit int) ([]domain.User, error)
-		Get(id string) (*domain.User, error)
-		Update(id string, firstName, lastName, email, phone *string) error
-		Delete(id string) error
-		Count(filters Filters) (int, error)
+		Create(ctx context.Context, firstName, lastName, email, phone, password string, role *string) (*domain.User, error)
+		GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.User, error)
+		Get(ctx context.Context, id string) (*domain.User, error)
+		GetByEmail(ctx context.Context, email string) (*domain.User, error)
+		Lookup(ctx context.Context, id string) (*domain.User, error)
+		Update(ctx context.Context, id string, firstName, lastName, email, phone, role *string) (*domain.User, error)
+		SetPassword(ctx context.Context, id string, currentPassword *string, password string) error
+		RequestVerification(ctx context.Context, id string) error
+		VerifyEmail(ctx context.Context, id, verificationToken string) error
+		RequestPasswordReset(ctx context.Context, email string) error
+		ResetPassword(ctx context.Context, resetToken, password string) (string, error)
+		Delete(ctx context.Context, id string) error
+		Restore(ctx context.Context, id string) (*domain.User, error)
+		DeletePermanently(ctx context.Context, id string) error
+		Count(ctx context.Context, filters Filters) (int, error)
+	}
+
+	// EnrollmentCanceler cancels the enrollments of a user being deleted,
+	// joining the transaction carried by ctx. The enrollment package provides
+	// it, which can't be imported from here.
+	EnrollmentCanceler interface {
+		CancelForUser(ctx context.Context, userId string) error
 	}
 
 	service struct {
-		log        *log.Logger
-		repository Repository
+		log              *slog.Logger
+		repository       Repository
+		mailer           mail.Sender
+		background       *background.Runner
+		enrollments      EnrollmentCanceler
+		verificationTTL  time.Duration
+		passwordResetTTL time.Duration
 	}
 )
 
-func NewService(log *log.Logger, repo Repository) Service {
+func NewService(log *slog.Logger, repo Repository, mailer mail.Sender, runner *background.Runner, enrollments EnrollmentCanceler, verificationTTL, passwordResetTTL time.Duration) Service {
 	return &service{
-		log:        log,
-		repository: repo,
+		log:              log,
+		repository:       repo,
+		mailer:           mailer,
+		background:       runner,
+		enrollments:      enrollments,
+		verificationTTL:  verificationTTL,
+		passwordResetTTL: passwordResetTTL,
 	}
 }
 
-func (srv *service) Create(firstName, lastName, email, phone string) (*domain.User, error) {
-	srv.log.Println("create user service")
+func (srv *service) Create(ctx context.Context, firstName, lastName, email, phone, password string, role *string) (*domain.User, error) {
+	srv.log.DebugContext(ctx, "create user")
 	user := domain.User{
 		FirstName: firstName,
 		LastName:  lastName,
-		Email:     email,
+		Email:     domain.NormalizeEmail(email),
 		Phone:     phone,
+		Role:      domain.RoleStudent,
+	}
+	if role != nil {
+		user.Role = domain.Role(*role)
 	}
-	if err := srv.repository.Create(&user); err != nil {
-		// srv.log.Println(err)
+	if err := policy.CanAssignRole(policy.FromContext(ctx), user.Role); err != nil {
 		return nil, err
 	}
+	// the password is optional here, it can be set later through SetPassword
+	if password != "" {
+		if err := user.SetPassword(password); err != nil {
+			return nil, err
+		}
+	}
+	if err := srv.repository.Create(ctx, &user); err != nil {
+		return nil, err
+	}
+	srv.sendVerification(ctx, &user)
 	return &user, nil
 }
 
-func (srv *service) GetAll(filters Filters, offset, limit int) ([]domain.User, error) {
-	srv.log.Println("get all users service")
-	users, err := srv.repository.GetAll(filters, offset, limit)
+func (srv *service) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.User, error) {
+	srv.log.DebugContext(ctx, "get all users")
+	if err := checkFilters(ctx, filters); err != nil {
+		return nil, err
+	}
+	users, err := srv.repository.GetAll(ctx, filters, offset, limit)
 	if err != nil {
-		// srv.log.Println(err)
 		return nil, err
 	}
 	return users, nil
 }
 
-func (srv *service) Get(id string) (*domain.User, error) {
-	srv.log.Println("get user service")
-	user, err := srv.repository.Get(id)
+func (srv *service) Get(ctx context.Context, id string) (*domain.User, error) {
+	srv.log.DebugContext(ctx, "get user")
+	if err := policy.CanReadUser(policy.FromContext(ctx), id); err != nil {
+		return nil, err
+	}
+	user, err := srv.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (srv *service) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	srv.log.DebugContext(ctx, "get user by email")
+	return srv.repository.GetByEmail(ctx, domain.NormalizeEmail(email))
+}
+
+// Lookup returns the user without checking that the caller may read it, like
+// GetByEmail, for flows where a credential already proves who the caller is.
+func (srv *service) Lookup(ctx context.Context, id string) (*domain.User, error) {
+	srv.log.DebugContext(ctx, "lookup user")
+	return srv.repository.Get(ctx, id)
+}
+
+func (srv *service) Update(ctx context.Context, id string, firstName, lastName, email, phone, role *string) (*domain.User, error) {
+	srv.log.DebugContext(ctx, "update user")
+	current, err := srv.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// only a different address resets the verification
+	if email != nil {
+		normalized := domain.NormalizeEmail(*email)
+		email = &normalized
+		if normalized == current.Email {
+			email = nil
+		}
+	}
+
+	actor := policy.FromContext(ctx)
+	if err := policy.CanUpdateUser(actor, current, email != nil); err != nil {
+		return nil, err
+	}
+
+	var newRole *domain.Role
+	if role != nil && domain.Role(*role) != current.Role {
+		newRole = (*domain.Role)(role)
+		if err := policy.CanChangeRole(actor); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := srv.repository.Update(ctx, id, firstName, lastName, email, phone, newRole); err != nil {
+		return nil, err
+	}
+
+	user, err := srv.repository.Get(ctx, id)
 	if err != nil {
-		// srv.log.Println(err)
 		return nil, err
 	}
+	if email != nil {
+		srv.sendVerification(ctx, user)
+	}
 	return user, nil
 }
 
-func (srv *service) Update(id string, firstName, lastName, email, phone *string) error {
-	srv.log.Println("update user service")
-	return srv.repository.Update(id, firstName, lastName, email, phone)
+// SetPassword replaces the user's password. Once a password has been set the
+// current one must be provided to change it.
+func (srv *service) SetPassword(ctx context.Context, id string, currentPassword *string, password string) error {
+	srv.log.DebugContext(ctx, "set user password")
+	if err := policy.CanSetPassword(policy.FromContext(ctx), id); err != nil {
+		return err
+	}
+	user, err := srv.repository.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if user.HasPassword() {
+		if currentPassword == nil {
+			return apperr.Validation(apperr.FieldError{
+				Field:   "current_password",
+				Code:    "required",
+				Message: "Current password is required",
+			})
+		}
+
+		ok, err := user.CheckPassword(*currentPassword)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return domain.ErrPasswordMismatch
+		}
+	}
+
+	if err := user.SetPassword(password); err != nil {
+		return err
+	}
+
+	return srv.repository.UpdatePassword(ctx, id, user.PasswordHash)
+}
+
+// RequestVerification mails a new verification token, invalidating earlier
+// ones.
+func (srv *service) RequestVerification(ctx context.Context, id string) error {
+	srv.log.DebugContext(ctx, "request email verification")
+	user, err := srv.repository.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := policy.CanUpdateUser(policy.FromContext(ctx), user, false); err != nil {
+		return err
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	srv.sendVerification(ctx, user)
+	return nil
 }
 
-func (srv *service) Delete(id string) error {
-	srv.log.Println("delete user service")
-	return srv.repository.Delete(id)
+// VerifyEmail doesn't require authentication, holding a token mailed to the
+// user's address is the proof.
+func (srv *service) VerifyEmail(ctx context.Context, id, verificationToken string) error {
+	srv.log.DebugContext(ctx, "verify email")
+	return srv.repository.Transaction(ctx, func(repo Repository) error {
+		now := time.Now()
+		if err := repo.UseToken(ctx, id, domain.TokenEmailVerification, token.Hash(verificationToken), now); err != nil {
+			return err
+		}
+		return repo.MarkEmailVerified(ctx, id, now)
+	})
 }
 
-func (srv *service) Count(filters Filters) (int, error) {
-	srv.log.Println("count user service")
-	return srv.repository.Count(filters)
+// RequestPasswordReset mails a reset token when email belongs to a user.
+// Unknown addresses are silently ignored so callers can't tell them apart.
+func (srv *service) RequestPasswordReset(ctx context.Context, email string) error {
+	srv.log.DebugContext(ctx, "request password reset")
+	user, err := srv.repository.GetByEmail(ctx, domain.NormalizeEmail(email))
+	if err != nil {
+		if apperr.From(err).Kind == apperr.KindNotFound {
+			return nil
+		}
+		return err
+	}
+
+	issuedAt, err := srv.repository.LastTokenIssuedAt(ctx, user.Id, domain.TokenPasswordReset)
+	if err != nil {
+		return err
+	}
+	if issuedAt != nil && time.Since(*issuedAt) < passwordResetInterval {
+		srv.log.DebugContext(ctx, "password reset requested too often", "id", user.Id)
+		return nil
+	}
+
+	raw, expiresAt, err := srv.issueToken(ctx, user, domain.TokenPasswordReset, srv.passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return srv.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset your password. Send this token with your new password to POST /auth/reset-password:\n\n%s\n\nThe token expires on %s. If it wasn't you, ignore this email.\n",
+			user.FirstName, raw, expiresAt.Format(time.RFC1123)),
+	})
+}
+
+// ResetPassword sets password for the owner of resetToken, consuming the token,
+// and returns the user's id. It joins the transaction carried by ctx, if any.
+func (srv *service) ResetPassword(ctx context.Context, resetToken, password string) (string, error) {
+	srv.log.DebugContext(ctx, "reset password")
+	if err := domain.ValidatePassword(password); err != nil {
+		return "", err
+	}
+
+	var userId string
+	err := srv.repository.Transaction(ctx, func(repo Repository) error {
+		now := time.Now()
+		tokenHash := token.Hash(resetToken)
+
+		stored, err := repo.GetActiveToken(ctx, domain.TokenPasswordReset, tokenHash, now)
+		if err != nil {
+			return err
+		}
+
+		user, err := repo.Get(ctx, stored.UserId)
+		if err != nil {
+			if apperr.From(err).Kind == apperr.KindNotFound {
+				return ErrInvalidToken
+			}
+			return err
+		}
+
+		if err := user.SetPassword(password); err != nil {
+			return err
+		}
+
+		if err := repo.UseToken(ctx, user.Id, domain.TokenPasswordReset, tokenHash, now); err != nil {
+			return err
+		}
+
+		userId = user.Id
+		return repo.UpdatePassword(ctx, user.Id, user.PasswordHash)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return userId, nil
+}
+
+func (srv *service) Delete(ctx context.Context, id string) error {
+	srv.log.DebugContext(ctx, "delete user")
+	user, err := srv.repository.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := policy.CanDeleteUser(policy.FromContext(ctx), user); err != nil {
+		return err
+	}
+
+	return srv.repository.Transaction(ctx, func(repo Repository) error {
+		if err := repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return srv.enrollments.CancelForUser(repo.WithTx(ctx), id)
+	})
+}
+
+func (srv *service) Restore(ctx context.Context, id string) (*domain.User, error) {
+	srv.log.DebugContext(ctx, "restore user")
+	if err := policy.CanManageDeleted(policy.FromContext(ctx)); err != nil {
+		return nil, err
+	}
+	if err := srv.repository.Restore(ctx, id); err != nil {
+		return nil, err
+	}
+	return srv.repository.Get(ctx, id)
+}
+
+func (srv *service) DeletePermanently(ctx context.Context, id string) error {
+	srv.log.DebugContext(ctx, "delete user permanently")
+	if err := policy.CanManageDeleted(policy.FromContext(ctx)); err != nil {
+		return err
+	}
+	return srv.repository.DeletePermanently(ctx, id)
+}
+
+func (srv *service) Count(ctx context.Context, filters Filters) (int, error) {
+	srv.log.DebugContext(ctx, "count user")
+	if err := checkFilters(ctx, filters); err != nil {
+		return 0, err
+	}
+	return srv.repository.Count(ctx, filters)
+}
+
+func checkFilters(ctx context.Context, filters Filters) error {
+	actor := policy.FromContext(ctx)
+	if err := policy.CanListUsers(actor); err != nil {
+		return err
+	}
+
+	if err := filters.Deleted.Validate(); err != nil {
+		return err
+	}
+	if filters.Deleted != domain.DeletedExclude {
+		return policy.CanManageDeleted(actor)
+	}
+	return nil
+}
+
+// sendVerification mails a verification token in the background so a slow
+// mail server doesn't hold up the request. Failing to send is only logged since
+// the user can ask for another token.
+func (srv *service) sendVerification(ctx context.Context, user *domain.User) {
+	recipient := *user
+	srv.background.Go(ctx, "send verification email", func(ctx context.Context) error {
+		return srv.issueVerification(ctx, &recipient)
+	})
+}
+
+func (srv *service) issueVerification(ctx context.Context, user *domain.User) error {
+	raw, expiresAt, err := srv.issueToken(ctx, user, domain.TokenEmailVerification, srv.verificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return srv.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by sending this token to POST /users/%s/verify:\n\n%s\n\nThe token expires on %s.\n",
+			user.FirstName, user.Id, raw, expiresAt.Format(time.RFC1123)),
+	})
+}
+
+// issueToken stores a new token of purpose for user, invalidating the ones
+// issued before, and returns the plain token.
+func (srv *service) issueToken(ctx context.Context, user *domain.User, purpose domain.TokenPurpose, ttl time.Duration) (string, time.Time, error) {
+	raw, err := token.Opaque()
+	if err != nil {
+		return "", time.Time{}, apperr.Internal(err)
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	err = srv.repository.Transaction(ctx, func(repo Repository) error {
+		if err := repo.InvalidateTokens(ctx, user.Id, purpose); err != nil {
+			return err
+		}
+
+		return repo.CreateToken(ctx, &domain.UserToken{
+			UserId:    user.Id,
+			Purpose:   purpose,
+			TokenHash: token.Hash(raw),
+			ExpiresAt: expiresAt,
+		})
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return raw, expiresAt, nil
 }
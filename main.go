@@ -1,68 +1,235 @@
 package main
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/zchelalo/rest-api-go/internal/apikey"
+	"github.com/zchelalo/rest-api-go/internal/auth"
 	"github.com/zchelalo/rest-api-go/internal/course"
+	"github.com/zchelalo/rest-api-go/internal/domain"
 	"github.com/zchelalo/rest-api-go/internal/enrollment"
+	"github.com/zchelalo/rest-api-go/internal/health"
+	"github.com/zchelalo/rest-api-go/internal/purge"
 	"github.com/zchelalo/rest-api-go/internal/user"
+	"github.com/zchelalo/rest-api-go/migrations"
+	"github.com/zchelalo/rest-api-go/pkg/background"
 	"github.com/zchelalo/rest-api-go/pkg/bootstrap"
+	"github.com/zchelalo/rest-api-go/pkg/config"
+	"github.com/zchelalo/rest-api-go/pkg/middleware"
+	"github.com/zchelalo/rest-api-go/pkg/migrate"
+	"github.com/zchelalo/rest-api-go/pkg/token"
+)
+
+const (
+	backgroundTaskLimit   = 100
+	backgroundTaskTimeout = 30 * time.Second
 )
 
 func main() {
-	logger := bootstrap.InitLogger()
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("loading configuration", "error", err)
+		os.Exit(1)
+	}
+
+	logger, err := bootstrap.InitLogger(cfg.Log)
+	if err != nil {
+		slog.Error("initializing logger", "error", err)
+		os.Exit(1)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(logger, cfg, os.Args[2:]); err != nil {
+			fatal(logger, "running migrations", err)
+		}
+		return
+	}
 
-	err := godotenv.Load()
+	db, err := bootstrap.DBConnection(cfg.DB)
 	if err != nil {
-		logger.Fatal("error loading .env file")
+		fatal(logger, "connecting to database", err)
 	}
-	port := os.Getenv("PORT")
 
-	db, err := bootstrap.DBConnection()
+	migrator, err := migrate.New(logger, db, migrations.FS)
 	if err != nil {
-		logger.Fatal(err)
+		fatal(logger, "loading migrations", err)
 	}
 
+	if err := migrator.Check(); err != nil {
+		fatal(logger, "database schema is not up to date, run the migrate up command first", err)
+	}
+
+	mailer, err := bootstrap.MailSender(logger, cfg.Mail)
+	if err != nil {
+		fatal(logger, "initializing mail sender", err)
+	}
+
+	// work that outlives its request, like sending emails, is drained on
+	// shutdown before the database is closed
+	runner := background.NewRunner(logger, backgroundTaskLimit, backgroundTaskTimeout)
+
 	router := http.NewServeMux()
 
+	healthService := health.NewService(logger, db, migrator)
+	healthEndpoints := health.MakeEndpoints(healthService)
+
+	router.HandleFunc("GET /healthz", healthEndpoints.Liveness)
+	router.HandleFunc("GET /readyz", healthEndpoints.Readiness)
+
+	tokenManager := token.NewManager(cfg.Auth.TokenSecret, cfg.Auth.TokenIssuer, cfg.Auth.AccessTokenTTL)
+
+	apiKeyRepository := apikey.NewRepository(logger, db)
+	apiKeyService := apikey.NewService(logger, apiKeyRepository)
+	apiKeyEndpoints := apikey.MakeEndpoints(apiKeyService)
+
+	// scope is the route group an api key must be granted, routes without one
+	// only accept user access tokens
+	authenticated := func(scope domain.Scope, handler func(http.ResponseWriter, *http.Request)) http.Handler {
+		return middleware.Authenticate(tokenManager, apiKeyService, string(scope))(http.HandlerFunc(handler))
+	}
+
+	router.Handle("POST /api-keys", authenticated("", apiKeyEndpoints.Create))
+	router.Handle("GET /api-keys", authenticated("", apiKeyEndpoints.GetAll))
+	router.Handle("DELETE /api-keys/{id}", authenticated("", apiKeyEndpoints.Revoke))
+
 	userRepository := user.NewRepository(logger, db)
-	userService := user.NewService(logger, userRepository)
-	userEndpoints := user.MakeEndpoints(userService)
+	enrollmentRepository := enrollment.NewRepository(logger, db)
 
-	router.HandleFunc("GET /users", userEndpoints.GetAll)
-	router.HandleFunc("GET /users/{id}", userEndpoints.Get)
-	router.HandleFunc("POST /users", userEndpoints.Create)
-	router.HandleFunc("PATCH /users/{id}", userEndpoints.Update)
-	router.HandleFunc("DELETE /users/{id}", userEndpoints.Delete)
+	enrollmentCascade := enrollment.NewCascade(enrollmentRepository, logger)
+	userService := user.NewService(logger, userRepository, mailer, runner, enrollmentCascade, cfg.Auth.VerificationTTL, cfg.Auth.PasswordResetTTL)
+	userEndpoints := user.MakeEndpoints(userService, user.Config{LimPageDef: cfg.Paginator.LimitDefault})
+
+	router.Handle("GET /users", authenticated(domain.ScopeUsers, userEndpoints.GetAll))
+	router.Handle("GET /users/{id}", authenticated(domain.ScopeUsers, userEndpoints.Get))
+	// signing up doesn't require an account, admins send theirs to create users
+	// with other roles
+	router.Handle("POST /users", middleware.OptionalAuthenticate(tokenManager, apiKeyService, string(domain.ScopeUsers))(http.HandlerFunc(userEndpoints.Create)))
+	router.Handle("PATCH /users/{id}", authenticated(domain.ScopeUsers, userEndpoints.Update))
+	router.Handle("PUT /users/{id}/password", authenticated(domain.ScopeUsers, userEndpoints.SetPassword))
+	router.Handle("POST /users/{id}/verification", authenticated(domain.ScopeUsers, userEndpoints.RequestVerification))
+	// the mailed token is what authenticates the verification
+	router.HandleFunc("POST /users/{id}/verify", userEndpoints.Verify)
+	router.Handle("DELETE /users/{id}", authenticated(domain.ScopeUsers, userEndpoints.Delete))
+	router.Handle("POST /users/{id}/restore", authenticated(domain.ScopeUsers, userEndpoints.Restore))
+	router.Handle("DELETE /users/{id}/permanent", authenticated(domain.ScopeUsers, userEndpoints.DeletePermanently))
+
+	authRepository := auth.NewRepository(logger, db)
+	authService := auth.NewService(logger, authRepository, userService, tokenManager, runner, cfg.Auth.RefreshTokenTTL)
+	authEndpoints := auth.MakeEndpoints(authService)
+
+	router.HandleFunc("POST /auth/login", authEndpoints.Login)
+	router.HandleFunc("POST /auth/refresh", authEndpoints.Refresh)
+	router.HandleFunc("POST /auth/logout", authEndpoints.Logout)
+	router.HandleFunc("POST /auth/forgot-password", authEndpoints.ForgotPassword)
+	router.HandleFunc("POST /auth/reset-password", authEndpoints.ResetPassword)
 
 	courseRepository := course.NewRepository(logger, db)
-	courseService := course.NewService(courseRepository, logger)
-	courseEndpoints := course.MakeEndpoints(courseService)
+	courseService := course.NewService(courseRepository, logger, userService, enrollmentCascade)
+	courseEndpoints := course.MakeEndpoints(courseService, course.Config{LimPageDef: cfg.Paginator.LimitDefault})
 
-	router.HandleFunc("POST /courses", courseEndpoints.Create)
-	router.HandleFunc("GET /courses", courseEndpoints.GetAll)
-	router.HandleFunc("GET /courses/{id}", courseEndpoints.Get)
-	router.HandleFunc("PATCH /courses/{id}", courseEndpoints.Update)
-	router.HandleFunc("DELETE /courses/{id}", courseEndpoints.Delete)
+	router.Handle("POST /courses", authenticated(domain.ScopeCourses, courseEndpoints.Create))
+	router.Handle("GET /courses", authenticated(domain.ScopeCourses, courseEndpoints.GetAll))
+	router.Handle("GET /courses/{id}", authenticated(domain.ScopeCourses, courseEndpoints.Get))
+	router.Handle("PATCH /courses/{id}", authenticated(domain.ScopeCourses, courseEndpoints.Update))
+	router.Handle("DELETE /courses/{id}", authenticated(domain.ScopeCourses, courseEndpoints.Delete))
+	router.Handle("POST /courses/{id}/restore", authenticated(domain.ScopeCourses, courseEndpoints.Restore))
+	router.Handle("DELETE /courses/{id}/permanent", authenticated(domain.ScopeCourses, courseEndpoints.DeletePermanently))
 
-	enrollmentRepository := enrollment.NewRepository(logger, db)
 	enrollmentService := enrollment.NewService(enrollmentRepository, logger, userService, courseService)
-	enrollmentEndpoints := enrollment.MakeEndpoints(enrollmentService)
+	enrollmentEndpoints := enrollment.MakeEndpoints(enrollmentService, enrollment.Config{LimPageDef: cfg.Paginator.LimitDefault})
+
+	router.Handle("POST /enrollments", authenticated(domain.ScopeEnrollments, enrollmentEndpoints.Create))
+	router.Handle("GET /enrollments", authenticated(domain.ScopeEnrollments, enrollmentEndpoints.GetAll))
+	router.Handle("GET /enrollments/{id}", authenticated(domain.ScopeEnrollments, enrollmentEndpoints.Get))
+	router.Handle("PATCH /enrollments/{id}", authenticated(domain.ScopeEnrollments, enrollmentEndpoints.Update))
+	router.Handle("DELETE /enrollments/{id}", authenticated(domain.ScopeEnrollments, enrollmentEndpoints.Delete))
 
-	router.HandleFunc("POST /enrollments", enrollmentEndpoints.Create)
+	handler := middleware.Chain(router,
+		middleware.RequestID(),
+		middleware.AccessLog(logger, router),
+		middleware.Recover(logger),
+		middleware.Timeout(cfg.Server.RequestTimeout),
+	)
 
 	server := &http.Server{
-		// Handler:      http.TimeoutHandler(router, 5*time.Second, "Timeout!"),
-		Handler:      router,
-		Addr:         fmt.Sprintf("127.0.0.1%s", port),
-		WriteTimeout: 5 * time.Second,
-		ReadTimeout:  5 * time.Second,
+		Handler:        handler,
+		Addr:           cfg.Server.Addr(),
+		ReadTimeout:    cfg.Server.ReadTimeout,
+		WriteTimeout:   cfg.Server.WriteTimeout,
+		IdleTimeout:    cfg.Server.IdleTimeout,
+		MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// closed once the purge loop is done, so the database isn't closed under it
+	purgeDone := make(chan struct{})
+	if cfg.Purge.Retention > 0 {
+		purgeService := purge.NewService(logger, purge.NewRepository(logger, db), cfg.Purge.Retention, cfg.Purge.Interval)
+		go func() {
+			defer close(purgeDone)
+			purgeService.Run(ctx)
+		}()
+	} else {
+		close(purgeDone)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("server listening", "addr", server.Addr, "tls", cfg.Server.TLS())
+		if cfg.Server.TLS() {
+			serverErr <- server.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		} else {
+			serverErr <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
+		fatal(logger, "server stopped unexpectedly", err)
+	case <-ctx.Done():
+		logger.Info("shutting down server")
 	}
 
-	log.Fatal(server.ListenAndServe())
+	// fail readiness first and give load balancers time to notice before the
+	// listener stops accepting connections
+	healthService.SetShuttingDown()
+	time.Sleep(cfg.Server.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("server shutdown", "error", err)
+	}
+
+	if err := runner.Wait(shutdownCtx); err != nil {
+		logger.Error("waiting for background tasks", "error", err)
+	}
+
+	select {
+	case <-purgeDone:
+	case <-shutdownCtx.Done():
+		logger.Error("waiting for the purge", "error", shutdownCtx.Err())
+	}
+
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			logger.Error("closing database", "error", err)
+		}
+	}
+
+	logger.Info("server stopped")
+}
+
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/zchelalo/rest-api-go/migrations"
+	"github.com/zchelalo/rest-api-go/pkg/bootstrap"
+	"github.com/zchelalo/rest-api-go/pkg/config"
+	"github.com/zchelalo/rest-api-go/pkg/migrate"
+)
+
+const migrateUsage = "usage: migrate up|down|status|create <name>"
+
+func runMigrate(logger *slog.Logger, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if args[0] == "create" {
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+
+		files, err := migrate.Create(cfg.MigrationsDir, args[1])
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			logger.Info("migration file created", "path", file)
+		}
+		return nil
+	}
+
+	db, err := bootstrap.DBConnection(cfg.DB)
+	if err != nil {
+		return err
+	}
+
+	migrator, err := migrate.New(logger, db, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		return migrator.Up()
+	case "down":
+		return migrator.Down()
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied at " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return nil
+	}
+
+	return errors.New(migrateUsage)
+}
@@ -0,0 +1,58 @@
+-- IF NOT EXISTS lets databases previously created with DB_AUTO_MIGRATE adopt
+-- the versioned migrations without recreating their tables. Those tables date
+-- from before capacity, enrollment windows and waitlists, so the columns added
+-- since are brought in below.
+
+CREATE TABLE IF NOT EXISTS users (
+    id         char(36)     NOT NULL PRIMARY KEY,
+    first_name varchar(100) NOT NULL,
+    last_name  varchar(100) NOT NULL,
+    email      varchar(100) NOT NULL CONSTRAINT uni_users_email UNIQUE,
+    phone      varchar(30)  NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS courses (
+    id                   char(36)    NOT NULL PRIMARY KEY,
+    name                 varchar(50) NOT NULL,
+    start_date           timestamptz NOT NULL,
+    end_date             timestamptz NOT NULL,
+    capacity             bigint      NOT NULL DEFAULT 0,
+    enrollment_opens_at  timestamptz,
+    enrollment_closes_at timestamptz,
+    created_at           timestamptz,
+    updated_at           timestamptz,
+    deleted_at           timestamptz
+);
+
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS capacity bigint NOT NULL DEFAULT 0;
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS enrollment_opens_at timestamptz;
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS enrollment_closes_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_courses_deleted_at ON courses (deleted_at);
+
+CREATE TABLE IF NOT EXISTS enrollments (
+    id                char(36)   NOT NULL PRIMARY KEY,
+    user_id           char(36)   NOT NULL CONSTRAINT fk_enrollments_user REFERENCES users (id),
+    course_id         char(36)   NOT NULL CONSTRAINT fk_enrollments_course REFERENCES courses (id),
+    status            varchar(2) NOT NULL,
+    waitlist_position bigint,
+    created_at        timestamptz,
+    updated_at        timestamptz,
+    deleted_at        timestamptz
+);
+
+ALTER TABLE enrollments ADD COLUMN IF NOT EXISTS waitlist_position bigint;
+
+-- auto migrated tables kept status as a nullable char(2), which pads "P" to
+-- "P " so it never matches a status again
+UPDATE enrollments SET status = 'P' WHERE status IS NULL;
+ALTER TABLE enrollments ALTER COLUMN status TYPE varchar(2) USING trim(status);
+ALTER TABLE enrollments ALTER COLUMN status SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_enrollments_deleted_at ON enrollments (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_enrollments_user_course ON enrollments (user_id, course_id) WHERE deleted_at IS NULL;
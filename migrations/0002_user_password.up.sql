@@ -0,0 +1,2 @@
+-- existing users keep an empty hash until they set a password
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash varchar(255) NOT NULL DEFAULT '';
@@ -0,0 +1,11 @@
+CREATE TABLE refresh_tokens (
+    id         char(36)    NOT NULL PRIMARY KEY,
+    user_id    char(36)    NOT NULL CONSTRAINT fk_refresh_tokens_user REFERENCES users (id) ON DELETE CASCADE,
+    token_hash char(64)    NOT NULL,
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz,
+    created_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens (user_id);
@@ -0,0 +1,3 @@
+ALTER TABLE courses DROP COLUMN IF EXISTS instructor_id;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,7 @@
+-- promote the first administrator by hand once it has signed up:
+--   UPDATE users SET role = 'admin' WHERE email = '...';
+ALTER TABLE users ADD COLUMN role varchar(20) NOT NULL DEFAULT 'student';
+
+ALTER TABLE courses ADD COLUMN instructor_id char(36) CONSTRAINT fk_courses_instructor REFERENCES users (id);
+
+CREATE INDEX idx_courses_instructor_id ON courses (instructor_id);
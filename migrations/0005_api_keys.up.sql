@@ -0,0 +1,13 @@
+CREATE TABLE api_keys (
+    id           char(36)     NOT NULL PRIMARY KEY,
+    name         varchar(100) NOT NULL,
+    prefix       varchar(16)  NOT NULL,
+    key_hash     char(64)     NOT NULL,
+    scopes       jsonb        NOT NULL,
+    created_by   char(36)     NOT NULL CONSTRAINT fk_api_keys_created_by REFERENCES users (id),
+    last_used_at timestamptz,
+    revoked_at   timestamptz,
+    created_at   timestamptz
+);
+
+CREATE UNIQUE INDEX idx_api_keys_key_hash ON api_keys (key_hash);
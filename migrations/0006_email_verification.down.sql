@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS user_tokens;
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
+
+DROP INDEX IF EXISTS idx_users_email_lower;
+
+ALTER TABLE users ADD CONSTRAINT uni_users_email UNIQUE (email);
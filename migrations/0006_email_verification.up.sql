@@ -0,0 +1,22 @@
+-- fails when existing emails only differ in case, those have to be merged by
+-- hand before this migration can be applied
+UPDATE users SET email = lower(trim(email));
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS uni_users_email;
+
+CREATE UNIQUE INDEX idx_users_email_lower ON users (lower(email)) WHERE deleted_at IS NULL;
+
+ALTER TABLE users ADD COLUMN email_verified_at timestamptz;
+
+CREATE TABLE user_tokens (
+    id         char(36)    NOT NULL PRIMARY KEY,
+    user_id    char(36)    NOT NULL CONSTRAINT fk_user_tokens_user REFERENCES users (id) ON DELETE CASCADE,
+    purpose    varchar(30) NOT NULL,
+    token_hash char(64)    NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz,
+    created_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_user_tokens_token_hash ON user_tokens (token_hash);
+CREATE INDEX idx_user_tokens_user_id ON user_tokens (user_id);
@@ -0,0 +1,14 @@
+-- permanently deleting a user or course takes its enrollments with it, and
+-- courses and api keys outlive the users they point at
+ALTER TABLE enrollments DROP CONSTRAINT fk_enrollments_user;
+ALTER TABLE enrollments ADD CONSTRAINT fk_enrollments_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE enrollments DROP CONSTRAINT fk_enrollments_course;
+ALTER TABLE enrollments ADD CONSTRAINT fk_enrollments_course FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE;
+
+ALTER TABLE courses DROP CONSTRAINT fk_courses_instructor;
+ALTER TABLE courses ADD CONSTRAINT fk_courses_instructor FOREIGN KEY (instructor_id) REFERENCES users (id) ON DELETE SET NULL;
+
+ALTER TABLE api_keys ALTER COLUMN created_by DROP NOT NULL;
+ALTER TABLE api_keys DROP CONSTRAINT fk_api_keys_created_by;
+ALTER TABLE api_keys ADD CONSTRAINT fk_api_keys_created_by FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL;
@@ -0,0 +1,4 @@
+-- fails while a user has more than one live enrollment in a course, which
+-- re-enrolling allows; those have to be cleaned up by hand first
+DROP INDEX IF EXISTS idx_enrollments_user_course;
+CREATE UNIQUE INDEX idx_enrollments_user_course ON enrollments (user_id, course_id) WHERE deleted_at IS NULL;
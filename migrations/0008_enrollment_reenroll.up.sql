@@ -0,0 +1,4 @@
+-- only open enrollments keep a user from enrolling in the same course again,
+-- so dropped, rejected and completed ones stay as history
+DROP INDEX IF EXISTS idx_enrollments_user_course;
+CREATE UNIQUE INDEX idx_enrollments_user_course ON enrollments (user_id, course_id) WHERE deleted_at IS NULL AND status IN ('W', 'P', 'A', 'S');
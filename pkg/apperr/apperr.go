@@ -0,0 +1,164 @@
+package apperr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindBadRequest
+	KindNotFound
+	KindConflict
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindTimeout
+)
+
+type (
+	FieldError struct {
+		Field   string `json:"field"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+
+	FieldErrors []FieldError
+
+	Error struct {
+		Kind    Kind
+		Code    string
+		Message string
+		Fields  []FieldError
+		// Details is returned to the client as the response data, e.g. the
+		// counts behind a conflict.
+		Details interface{}
+		Err     error
+	}
+)
+
+func (err *Error) Error() string {
+	if err.Kind == KindValidation && len(err.Fields) > 0 {
+		messages := make([]string, 0, len(err.Fields))
+		for _, field := range err.Fields {
+			messages = append(messages, field.Field+": "+field.Message)
+		}
+		return err.Message + ": " + strings.Join(messages, ", ")
+	}
+	return err.Message
+}
+
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+func (err *Error) StatusCode() int {
+	switch err.Kind {
+	case KindBadRequest:
+		return http.StatusBadRequest
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindValidation:
+		return http.StatusUnprocessableEntity
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindTimeout:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+func BadRequest(code, message string) *Error {
+	return &Error{Kind: KindBadRequest, Code: code, Message: message}
+}
+
+func NotFound(code, message string) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: message}
+}
+
+func Conflict(code, message string) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: message}
+}
+
+func Unauthorized(code, message string) *Error {
+	return &Error{Kind: KindUnauthorized, Code: code, Message: message}
+}
+
+func Forbidden(code, message string) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message}
+}
+
+func Validation(fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: "validation_failed", Message: "validation failed", Fields: fields}
+}
+
+// Internal hides the cause from clients while keeping it reachable through
+// errors.Unwrap for logging.
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Code: "internal_error", Message: "internal server error", Err: err}
+}
+
+// From returns err as an *Error, treating anything that isn't one as internal.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal(err)
+}
+
+func StatusCode(err error) int {
+	return From(err).StatusCode()
+}
+
+// FromDB translates gorm errors into application errors for the given entity,
+// e.g. "user" yields a user_not_found code.
+func FromDB(err error, entity string) error {
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &Error{Kind: KindNotFound, Code: entity + "_not_found", Message: entity + " not found", Err: err}
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return &Error{Kind: KindConflict, Code: entity + "_already_exists", Message: entity + " already exists", Err: err}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Kind: KindTimeout, Code: "timeout", Message: "request timed out", Err: err}
+	case errors.Is(err, context.Canceled):
+		return &Error{Kind: KindTimeout, Code: "request_canceled", Message: "request was canceled", Err: err}
+	}
+
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return err
+	}
+	return Internal(err)
+}
+
+func (fields *FieldErrors) Add(field, code, message string) {
+	*fields = append(*fields, FieldError{
+		Field:   field,
+		Code:    code,
+		Message: message,
+	})
+}
+
+// Err returns nil when no field errors were collected so callers can return
+// the result directly.
+func (fields FieldErrors) Err() error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return Validation(fields...)
+}
@@ -0,0 +1,89 @@
+// Package background runs work that outlives the request which started it,
+// like sending an email, so shutdown can wait for it before closing the
+// resources it uses.
+package background
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type Runner struct {
+	log     *slog.Logger
+	timeout time.Duration
+	slots   chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	running sync.WaitGroup
+}
+
+// NewRunner allows up to limit tasks at once, each bounded by timeout.
+func NewRunner(log *slog.Logger, limit int, timeout time.Duration) *Runner {
+	return &Runner{
+		log:     log,
+		timeout: timeout,
+		slots:   make(chan struct{}, limit),
+	}
+}
+
+// Go runs fn with the values of ctx but not its cancellation. Tasks are
+// dropped, and logged, once limit of them are running or Wait has been
+// called, so a burst of requests can't pile up goroutines.
+func (runner *Runner) Go(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	if runner.closed {
+		runner.log.WarnContext(ctx, "background task dropped, shutting down", "task", name)
+		return
+	}
+
+	select {
+	case runner.slots <- struct{}{}:
+	default:
+		runner.log.WarnContext(ctx, "background task dropped, too many running", "task", name)
+		return
+	}
+
+	runner.running.Add(1)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), runner.timeout)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				runner.log.ErrorContext(ctx, "background task panicked", "task", name, "panic", fmt.Sprint(recovered))
+			}
+			cancel()
+			<-runner.slots
+			runner.running.Done()
+		}()
+
+		if err := fn(ctx); err != nil {
+			runner.log.ErrorContext(ctx, "background task failed", "task", name, "error", err)
+		}
+	}()
+}
+
+// Wait stops accepting tasks and blocks until the running ones finish or ctx
+// is done.
+func (runner *Runner) Wait(ctx context.Context) error {
+	runner.mu.Lock()
+	runner.closed = true
+	runner.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		runner.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
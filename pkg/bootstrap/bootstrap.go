@@ -1,51 +1,42 @@
 package bootstrap
 
 import (
-	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"strings"
 
+	"github.com/zchelalo/rest-api-go/pkg/config"
+	"github.com/zchelalo/rest-api-go/pkg/logging"
+	"github.com/zchelalo/rest-api-go/pkg/mail"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-
-	"github.com/zchelalo/rest-api-go/internal/domain"
 )
 
-func InitLogger() *log.Logger {
-	return log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
+func InitLogger(cfg config.Log) (*slog.Logger, error) {
+	return logging.New(os.Stdout, cfg.Format, cfg.Level)
 }
 
-func DBConnection() (*gorm.DB, error) {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=America/Hermosillo",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASS"),
-		os.Getenv("DB_NAME"),
-		os.Getenv("DB_PORT"),
-	)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+func DBConnection(cfg config.DB) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{
+		TranslateError: true,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if os.Getenv("DB_DEBUG") == "true" {
+	if cfg.Debug {
 		db = db.Debug()
 	}
 
-	if os.Getenv("DB_AUTO_MIGRATE") == "true" {
-		if err := db.AutoMigrate(&domain.User{}); err != nil {
-			return nil, err
-		}
-
-		if err := db.AutoMigrate(&domain.Course{}); err != nil {
-			return nil, err
-		}
+	return db, nil
+}
 
-		if err := db.AutoMigrate(&domain.Enrollment{}); err != nil {
-			return nil, err
-		}
+func MailSender(log *slog.Logger, cfg config.Mail) (mail.Sender, error) {
+	switch strings.ToLower(cfg.Driver) {
+	case "file":
+		return mail.NewFileSender(cfg.Dir, cfg.From)
+	case "smtp":
+		return mail.NewSMTPSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.From), nil
 	}
-
-	return db, nil
+	return mail.NewLogSender(log), nil
 }
@@ -0,0 +1,352 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	Config struct {
+		Server        Server    `json:"server" yaml:"server"`
+		Log           Log       `json:"log" yaml:"log"`
+		Auth          Auth      `json:"auth" yaml:"auth"`
+		Mail          Mail      `json:"mail" yaml:"mail"`
+		DB            DB        `json:"db" yaml:"db"`
+		Paginator     Paginator `json:"paginator" yaml:"paginator"`
+		Purge         Purge     `json:"purge" yaml:"purge"`
+		MigrationsDir string    `json:"migrations_dir" yaml:"migrations_dir" env:"MIGRATIONS_DIR"`
+	}
+
+	Server struct {
+		Host            string        `json:"host" yaml:"host" env:"SERVER_HOST"`
+		Port            string        `json:"port" yaml:"port" env:"PORT"`
+		ReadTimeout     time.Duration `json:"read_timeout" yaml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+		WriteTimeout    time.Duration `json:"write_timeout" yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+		IdleTimeout     time.Duration `json:"idle_timeout" yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+		ShutdownTimeout time.Duration `json:"shutdown_timeout" yaml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"`
+		ShutdownDelay   time.Duration `json:"shutdown_delay" yaml:"shutdown_delay" env:"SERVER_SHUTDOWN_DELAY"`
+		RequestTimeout  time.Duration `json:"request_timeout" yaml:"request_timeout" env:"SERVER_REQUEST_TIMEOUT"`
+		MaxHeaderBytes  int           `json:"max_header_bytes" yaml:"max_header_bytes" env:"SERVER_MAX_HEADER_BYTES"`
+		TLSCertFile     string        `json:"tls_cert_file" yaml:"tls_cert_file" env:"SERVER_TLS_CERT_FILE"`
+		TLSKeyFile      string        `json:"tls_key_file" yaml:"tls_key_file" env:"SERVER_TLS_KEY_FILE"`
+	}
+
+	Log struct {
+		Format string `json:"format" yaml:"format" env:"LOG_FORMAT"`
+		Level  string `json:"level" yaml:"level" env:"LOG_LEVEL"`
+	}
+
+	Auth struct {
+		TokenSecret      string        `json:"token_secret" yaml:"token_secret" env:"AUTH_TOKEN_SECRET"`
+		TokenIssuer      string        `json:"token_issuer" yaml:"token_issuer" env:"AUTH_TOKEN_ISSUER"`
+		AccessTokenTTL   time.Duration `json:"access_token_ttl" yaml:"access_token_ttl" env:"AUTH_ACCESS_TOKEN_TTL"`
+		RefreshTokenTTL  time.Duration `json:"refresh_token_ttl" yaml:"refresh_token_ttl" env:"AUTH_REFRESH_TOKEN_TTL"`
+		VerificationTTL  time.Duration `json:"verification_ttl" yaml:"verification_ttl" env:"AUTH_VERIFICATION_TTL"`
+		PasswordResetTTL time.Duration `json:"password_reset_ttl" yaml:"password_reset_ttl" env:"AUTH_PASSWORD_RESET_TTL"`
+	}
+
+	Mail struct {
+		Driver       string `json:"driver" yaml:"driver" env:"MAIL_DRIVER"`
+		From         string `json:"from" yaml:"from" env:"MAIL_FROM"`
+		Dir          string `json:"dir" yaml:"dir" env:"MAIL_DIR"`
+		SMTPHost     string `json:"smtp_host" yaml:"smtp_host" env:"MAIL_SMTP_HOST"`
+		SMTPPort     string `json:"smtp_port" yaml:"smtp_port" env:"MAIL_SMTP_PORT"`
+		SMTPUser     string `json:"smtp_user" yaml:"smtp_user" env:"MAIL_SMTP_USER"`
+		SMTPPassword string `json:"smtp_password" yaml:"smtp_password" env:"MAIL_SMTP_PASS"`
+	}
+
+	DB struct {
+		Host     string `json:"host" yaml:"host" env:"DB_HOST"`
+		User     string `json:"user" yaml:"user" env:"DB_USER"`
+		Password string `json:"password" yaml:"password" env:"DB_PASS"`
+		Name     string `json:"name" yaml:"name" env:"DB_NAME"`
+		Port     string `json:"port" yaml:"port" env:"DB_PORT"`
+		SSLMode  string `json:"ssl_mode" yaml:"ssl_mode" env:"DB_SSL_MODE"`
+		TimeZone string `json:"time_zone" yaml:"time_zone" env:"DB_TIME_ZONE"`
+		Debug    bool   `json:"debug" yaml:"debug" env:"DB_DEBUG"`
+	}
+
+	Paginator struct {
+		LimitDefault int `json:"limit_default" yaml:"limit_default" env:"PAGINATOR_LIMIT_DEFAULT"`
+	}
+
+	// Purge permanently deletes rows soft deleted more than Retention ago,
+	// checking every Interval. A zero retention, the default, disables it.
+	Purge struct {
+		Retention time.Duration `json:"retention" yaml:"retention" env:"PURGE_RETENTION"`
+		Interval  time.Duration `json:"interval" yaml:"interval" env:"PURGE_INTERVAL"`
+	}
+)
+
+func defaults() Config {
+	return Config{
+		Server: Server{
+			Host:            "127.0.0.1",
+			Port:            ":3333",
+			ReadTimeout:     5 * time.Second,
+			WriteTimeout:    5 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+			RequestTimeout:  4 * time.Second,
+			MaxHeaderBytes:  1 << 20,
+		},
+		Log: Log{
+			Format: "json",
+			Level:  "info",
+		},
+		Auth: Auth{
+			TokenIssuer:      "rest-api-go",
+			AccessTokenTTL:   15 * time.Minute,
+			RefreshTokenTTL:  30 * 24 * time.Hour,
+			VerificationTTL:  48 * time.Hour,
+			PasswordResetTTL: time.Hour,
+		},
+		Mail: Mail{
+			From:     "no-reply@localhost",
+			Dir:      "mail",
+			SMTPPort: "587",
+		},
+		DB: DB{
+			Port:     "5432",
+			SSLMode:  "disable",
+			TimeZone: "America/Hermosillo",
+		},
+		Paginator: Paginator{
+			LimitDefault: 10,
+		},
+		Purge: Purge{
+			Interval: 24 * time.Hour,
+		},
+		MigrationsDir: "migrations",
+	}
+}
+
+// Load builds the configuration from, in increasing priority: the defaults,
+// the YAML or JSON file named by CONFIG_FILE, and the environment. A .env file
+// in the working directory is read into the environment when present.
+func Load() (*Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("loading .env file: %w", err)
+	}
+
+	cfg := defaults()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := loadEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func (cfg *Config) Validate() error {
+	var errs []error
+
+	if cfg.Server.Port == "" {
+		errs = append(errs, errors.New("PORT is required"))
+	}
+
+	if cfg.Server.ReadTimeout <= 0 || cfg.Server.WriteTimeout <= 0 || cfg.Server.IdleTimeout <= 0 || cfg.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SERVER_READ_TIMEOUT, SERVER_WRITE_TIMEOUT, SERVER_IDLE_TIMEOUT and SERVER_SHUTDOWN_TIMEOUT must be positive durations"))
+	}
+
+	if cfg.Server.ShutdownDelay < 0 {
+		errs = append(errs, errors.New("SERVER_SHUTDOWN_DELAY can't be negative"))
+	}
+
+	if cfg.Server.RequestTimeout < 0 {
+		errs = append(errs, errors.New("SERVER_REQUEST_TIMEOUT can't be negative"))
+	}
+
+	if cfg.Server.MaxHeaderBytes <= 0 {
+		errs = append(errs, errors.New("SERVER_MAX_HEADER_BYTES must be greater than 0"))
+	}
+
+	if (cfg.Server.TLSCertFile == "") != (cfg.Server.TLSKeyFile == "") {
+		errs = append(errs, errors.New("SERVER_TLS_CERT_FILE and SERVER_TLS_KEY_FILE must be set together"))
+	}
+
+	if format := strings.ToLower(cfg.Log.Format); format != "json" && format != "text" {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT must be json or text, got %q", cfg.Log.Format))
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, got %q", cfg.Log.Level))
+	}
+
+	if len(cfg.Auth.TokenSecret) < 32 {
+		errs = append(errs, errors.New("AUTH_TOKEN_SECRET must have at least 32 characters"))
+	}
+
+	if cfg.Auth.AccessTokenTTL <= 0 || cfg.Auth.RefreshTokenTTL <= 0 || cfg.Auth.VerificationTTL <= 0 || cfg.Auth.PasswordResetTTL <= 0 {
+		errs = append(errs, errors.New("AUTH_ACCESS_TOKEN_TTL, AUTH_REFRESH_TOKEN_TTL, AUTH_VERIFICATION_TTL and AUTH_PASSWORD_RESET_TTL must be positive durations"))
+	}
+
+	switch driver := strings.ToLower(cfg.Mail.Driver); driver {
+	case "":
+		errs = append(errs, errors.New("MAIL_DRIVER is required"))
+	case "log", "file":
+	case "smtp":
+		if cfg.Mail.SMTPHost == "" {
+			errs = append(errs, errors.New("MAIL_SMTP_HOST is required when MAIL_DRIVER is smtp"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("MAIL_DRIVER must be log, file or smtp, got %q", cfg.Mail.Driver))
+	}
+
+	if cfg.DB.Host == "" {
+		errs = append(errs, errors.New("DB_HOST is required"))
+	}
+
+	if cfg.DB.User == "" {
+		errs = append(errs, errors.New("DB_USER is required"))
+	}
+
+	if cfg.DB.Name == "" {
+		errs = append(errs, errors.New("DB_NAME is required"))
+	}
+
+	if cfg.Paginator.LimitDefault <= 0 {
+		errs = append(errs, fmt.Errorf("PAGINATOR_LIMIT_DEFAULT must be greater than 0, got %d", cfg.Paginator.LimitDefault))
+	}
+
+	if cfg.Purge.Retention < 0 {
+		errs = append(errs, errors.New("PURGE_RETENTION can't be negative"))
+	}
+
+	if cfg.Purge.Retention > 0 && cfg.Purge.Interval <= 0 {
+		errs = append(errs, errors.New("PURGE_INTERVAL must be a positive duration"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Addr accepts PORT both as "3333" and in the older ":3333" form.
+func (server Server) Addr() string {
+	return net.JoinHostPort(server.Host, strings.TrimPrefix(server.Port, ":"))
+}
+
+func (server Server) TLS() bool {
+	return server.TLSCertFile != ""
+}
+
+func (db DB) DSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s",
+		db.Host,
+		db.User,
+		db.Password,
+		db.Name,
+		db.Port,
+		db.SSLMode,
+		db.TimeZone,
+	)
+}
+
+func loadFile(path string, cfg *Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	// JSON files go through the YAML decoder too, JSON being a subset of YAML,
+	// so durations like "5s" decode the same way in both formats
+	if err := yaml.Unmarshal(content, cfg); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// loadEnv overrides every field tagged with `env` whose variable is set.
+func loadEnv(value reflect.Value) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		structField := value.Type().Field(i)
+
+		if field.Kind() == reflect.Struct && structField.Type != reflect.TypeOf(time.Duration(0)) {
+			if err := loadEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := structField.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		if err := setField(field, raw); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", raw, name, err)
+		}
+	}
+
+	return nil
+}
+
+func setField(field reflect.Value, raw string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int64:
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(parsed))
+	case reflect.Slice:
+		var values []string
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// required holds the values Validate insists on, which have no defaults.
+func required() Config {
+	cfg := defaults()
+	cfg.Auth.TokenSecret = strings.Repeat("s", 32)
+	cfg.DB.Host = "localhost"
+	cfg.DB.User = "postgres"
+	cfg.DB.Name = "rest_api"
+	cfg.Mail.Driver = "log"
+	return cfg
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"yaml", "config.yaml", "server:\n  read_timeout: 7s\npaginator:\n  limit_default: 25\n"},
+		{"json", "config.json", `{"server":{"read_timeout":"7s"},"paginator":{"limit_default":25}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.file)
+			if err := os.WriteFile(path, []byte(test.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := defaults()
+			if err := loadFile(path, &cfg); err != nil {
+				t.Fatalf("loadFile() = %v", err)
+			}
+
+			if cfg.Server.ReadTimeout != 7*time.Second {
+				t.Errorf("ReadTimeout = %v, want 7s", cfg.Server.ReadTimeout)
+			}
+			if cfg.Paginator.LimitDefault != 25 {
+				t.Errorf("LimitDefault = %d, want 25", cfg.Paginator.LimitDefault)
+			}
+			// fields missing from the file keep their defaults
+			if cfg.Server.WriteTimeout != 5*time.Second {
+				t.Errorf("WriteTimeout = %v, want the 5s default", cfg.Server.WriteTimeout)
+			}
+		})
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   string
+		value string
+		check func(cfg Config) bool
+	}{
+		{"string", "DB_HOST", "db", func(cfg Config) bool { return cfg.DB.Host == "db" }},
+		{"int", "PAGINATOR_LIMIT_DEFAULT", "50", func(cfg Config) bool { return cfg.Paginator.LimitDefault == 50 }},
+		{"duration", "AUTH_ACCESS_TOKEN_TTL", "1h", func(cfg Config) bool { return cfg.Auth.AccessTokenTTL == time.Hour }},
+		{"nested duration", "PURGE_RETENTION", "2160h", func(cfg Config) bool { return cfg.Purge.Retention == 2160*time.Hour }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(test.env, test.value)
+
+			cfg := defaults()
+			if err := loadEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+				t.Fatalf("loadEnv() = %v", err)
+			}
+			if !test.check(cfg) {
+				t.Errorf("%s=%s wasn't applied", test.env, test.value)
+			}
+		})
+	}
+}
+
+func TestLoadEnvInvalid(t *testing.T) {
+	tests := []struct {
+		env   string
+		value string
+	}{
+		{"PAGINATOR_LIMIT_DEFAULT", "ten"},
+		{"SERVER_READ_TIMEOUT", "5"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.env, func(t *testing.T) {
+			t.Setenv(test.env, test.value)
+
+			cfg := defaults()
+			err := loadEnv(reflect.ValueOf(&cfg).Elem())
+			if err == nil || !strings.Contains(err.Error(), test.env) {
+				t.Errorf("loadEnv() = %v, want an error naming %s", err, test.env)
+			}
+		})
+	}
+}
+
+func TestLoadEnvOverridesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "server:\n  read_timeout: 7s\ndb:\n  host: file\n  user: postgres\n  name: rest_api\nmail:\n  driver: log\nauth:\n  token_secret: " + strings.Repeat("s", 32) + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("DB_HOST", "env")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if cfg.DB.Host != "env" {
+		t.Errorf("DB.Host = %q, want the environment to win", cfg.DB.Host)
+	}
+	if cfg.Server.ReadTimeout != 7*time.Second {
+		t.Errorf("ReadTimeout = %v, want 7s from the file", cfg.Server.ReadTimeout)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   string
+	}{
+		{"valid", func(cfg *Config) {}, ""},
+		{"short secret", func(cfg *Config) { cfg.Auth.TokenSecret = "short" }, "AUTH_TOKEN_SECRET"},
+		{"missing db host", func(cfg *Config) { cfg.DB.Host = "" }, "DB_HOST"},
+		{"zero read timeout", func(cfg *Config) { cfg.Server.ReadTimeout = 0 }, "SERVER_READ_TIMEOUT"},
+		{"negative request timeout", func(cfg *Config) { cfg.Server.RequestTimeout = -time.Second }, "SERVER_REQUEST_TIMEOUT"},
+		{"tls cert without key", func(cfg *Config) { cfg.Server.TLSCertFile = "cert.pem" }, "SERVER_TLS_KEY_FILE"},
+		{"log format", func(cfg *Config) { cfg.Log.Format = "xml" }, "LOG_FORMAT"},
+		{"log level", func(cfg *Config) { cfg.Log.Level = "loud" }, "LOG_LEVEL"},
+		{"missing mail driver", func(cfg *Config) { cfg.Mail.Driver = "" }, "MAIL_DRIVER"},
+		{"mail driver", func(cfg *Config) { cfg.Mail.Driver = "pigeon" }, "MAIL_DRIVER"},
+		{"smtp without host", func(cfg *Config) { cfg.Mail.Driver = "smtp" }, "MAIL_SMTP_HOST"},
+		{"paginator", func(cfg *Config) { cfg.Paginator.LimitDefault = 0 }, "PAGINATOR_LIMIT_DEFAULT"},
+		{"purge without interval", func(cfg *Config) { cfg.Purge.Retention = time.Hour; cfg.Purge.Interval = 0 }, "PURGE_INTERVAL"},
+		{"purge disabled by default", func(cfg *Config) { cfg.Purge.Interval = 0 }, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := required()
+			test.modify(&cfg)
+
+			err := cfg.Validate()
+			if test.want == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Validate() = %v, want an error about %s", err, test.want)
+			}
+		})
+	}
+}
+
+func TestServerAddr(t *testing.T) {
+	tests := []struct {
+		host, port string
+		want       string
+	}{
+		{"", "3333", ":3333"},
+		{"", ":3333", ":3333"},
+		{"127.0.0.1", "8080", "127.0.0.1:8080"},
+	}
+
+	for _, test := range tests {
+		server := Server{Host: test.host, Port: test.port}
+		if got := server.Addr(); got != test.want {
+			t.Errorf("Server{Host: %q, Port: %q}.Addr() = %q, want %q", test.host, test.port, got, test.want)
+		}
+	}
+}
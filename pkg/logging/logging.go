@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type attrsKey struct{}
+
+// New builds a logger writing to w in the given format ("json" or "text") that
+// also includes the attributes stored in the context of each record.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var slogLevel slog.Level
+	if err := slogLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	options := &slog.HandlerOptions{Level: slogLevel}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected json or text", format)
+	}
+
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+// WithAttrs returns a context carrying attrs so every record logged with it,
+// e.g. through InfoContext, includes them.
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (handler *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	return handler.Handler.Handle(ctx, record)
+}
+
+func (handler *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: handler.Handler.WithAttrs(attrs)}
+}
+
+func (handler *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: handler.Handler.WithGroup(name)}
+}
@@ -0,0 +1,77 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type (
+	Message struct {
+		To      string
+		Subject string
+		Body    string
+	}
+
+	// Sender delivers messages to users. Implementations must be safe for
+	// concurrent use.
+	Sender interface {
+		Send(ctx context.Context, msg Message) error
+	}
+
+	logSender struct {
+		log *slog.Logger
+	}
+
+	fileSender struct {
+		dir  string
+		from string
+	}
+)
+
+// NewLogSender writes every message to the log instead of delivering it,
+// meant for local runs. Bodies carry verification and reset tokens, so they
+// are only logged at debug level.
+func NewLogSender(log *slog.Logger) Sender {
+	return &logSender{log: log}
+}
+
+func (sender *logSender) Send(ctx context.Context, msg Message) error {
+	sender.log.InfoContext(ctx, "mail sent", "to", msg.To, "subject", msg.Subject)
+	sender.log.DebugContext(ctx, "mail body", "to", msg.To, "body", msg.Body)
+	return nil
+}
+
+// NewFileSender writes every message as an .eml file into dir so it can be
+// opened with a mail client.
+func NewFileSender(dir, from string) (Sender, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating mail directory: %w", err)
+	}
+	return &fileSender{dir: dir, from: from}, nil
+}
+
+func (sender *fileSender) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	name := fmt.Sprintf("%s_%s.eml", now.Format("20060102T150405"), uuid.New().String())
+
+	return os.WriteFile(filepath.Join(sender.dir, name), msg.bytes(sender.from, now), 0o644)
+}
+
+// bytes renders msg as a plain text RFC 5322 message.
+func (msg Message) bytes(from string, date time.Time) []byte {
+	var content strings.Builder
+	fmt.Fprintf(&content, "From: %s\r\n", from)
+	fmt.Fprintf(&content, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&content, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&content, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	content.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	content.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(content.String())
+}
@@ -0,0 +1,111 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// sendTimeout bounds a delivery whose context has no deadline of its own.
+const sendTimeout = 30 * time.Second
+
+type smtpSender struct {
+	host string
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPSender delivers messages through an SMTP server. The connection is
+// upgraded with STARTTLS whenever the server offers it, and credentials are
+// only used when username is set.
+func NewSMTPSender(host, port, username, password, from string) Sender {
+	sender := &smtpSender{
+		host: host,
+		addr: net.JoinHostPort(host, port),
+		from: from,
+	}
+	if username != "" {
+		sender.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return sender
+}
+
+// Send follows smtp.SendMail, which can't be cancelled, over a connection
+// that is closed as soon as ctx is done and carries its deadline.
+func (sender *smtpSender) Send(ctx context.Context, msg Message) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sendTimeout)
+		defer cancel()
+	}
+
+	if err := sender.send(ctx, msg); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return fmt.Errorf("sending mail to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+func (sender *smtpSender) send(ctx context.Context, msg Message) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", sender.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, sender.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: sender.host}); err != nil {
+			return err
+		}
+	}
+
+	if sender.auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := client.Auth(sender.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(sender.from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write(msg.bytes(sender.from, time.Now())); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
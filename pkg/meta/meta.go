@@ -1,10 +1,5 @@
 package meta
 
-import (
-	"os"
-	"strconv"
-)
-
 type Meta struct {
 	Page       int `json:"page"`
 	PerPage    int `json:"per_page"`
@@ -12,13 +7,9 @@ type Meta struct {
 	TotalCount int `json:"total_count"`
 }
 
-func New(page, perPage, total int) (*Meta, error) {
+func New(page, perPage, total, perPageDefault int) *Meta {
 	if perPage <= 0 {
-		var err error
-		perPage, err = strconv.Atoi(os.Getenv("PAGINATOR_LIMIT_DEFAULT"))
-		if err != nil {
-			return nil, err
-		}
+		perPage = perPageDefault
 	}
 
 	pageCount := 0
@@ -38,7 +29,7 @@ func New(page, perPage, total int) (*Meta, error) {
 		PerPage:    perPage,
 		PageCount:  pageCount,
 		TotalCount: total,
-	}, nil
+	}
 }
 
 func (meta *Meta) Offset() int {
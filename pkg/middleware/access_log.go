@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// AccessLog logs one record per request. The route is the pattern registered
+// on mux rather than the raw path so requests for different ids group together.
+func AccessLog(logger *slog.Logger, mux *http.ServeMux) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			recorder := &responseRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(recorder, req)
+
+			_, route := mux.Handler(req)
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+
+			level := slog.LevelInfo
+			if recorder.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("route", route),
+				slog.String("path", req.URL.Path),
+				slog.Int("status", recorder.status),
+				slog.Int("bytes", recorder.bytes),
+				slog.Duration("latency", time.Since(start)),
+			}
+			// internal errors reach the client as a generic message, the cause
+			// is only ever visible here
+			if recorder.err != nil {
+				attrs = append(attrs, slog.String("error", recorder.err.Error()))
+			}
+
+			logger.LogAttrs(req.Context(), level, "request", attrs...)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"github.com/zchelalo/rest-api-go/pkg/logging"
+	"github.com/zchelalo/rest-api-go/pkg/response"
+	"github.com/zchelalo/rest-api-go/pkg/token"
+)
+
+type (
+	userIDKey   struct{}
+	roleKey     struct{}
+	apiKeyIDKey struct{}
+
+	// APIKey is what an APIKeyVerifier resolves a key to. Role is the role
+	// the key acts with inside the scopes it was granted.
+	APIKey struct {
+		Id     string
+		Role   string
+		Scopes []string
+	}
+
+	APIKeyVerifier interface {
+		VerifyAPIKey(ctx context.Context, key string) (*APIKey, error)
+	}
+)
+
+// Authenticate rejects requests without valid credentials and stores the
+// caller's identity in the request context. Users send a bearer access token,
+// integrations send "ApiKey <key>" and are only let through when the key was
+// granted scope. An empty scope only accepts access tokens.
+func Authenticate(tokens *token.Manager, keys APIKeyVerifier, scope string) Middleware {
+	return authenticate(tokens, keys, scope, false)
+}
+
+// OptionalAuthenticate works like Authenticate but lets requests without an
+// Authorization header through as anonymous callers. Credentials that are sent
+// still have to be valid.
+func OptionalAuthenticate(tokens *token.Manager, keys APIKeyVerifier, scope string) Middleware {
+	return authenticate(tokens, keys, scope, true)
+}
+
+func authenticate(tokens *token.Manager, keys APIKeyVerifier, scope string, optional bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			scheme, raw, _ := strings.Cut(req.Header.Get("Authorization"), " ")
+			raw = strings.TrimSpace(raw)
+
+			switch {
+			case strings.EqualFold(scheme, "Bearer") && raw != "":
+				claims, err := tokens.Parse(raw)
+				if err != nil {
+					unauthorized(w, apperr.Unauthorized("invalid_token", "access token is invalid or expired"))
+					return
+				}
+
+				ctx := WithUser(req.Context(), claims.Subject, claims.Role)
+				next.ServeHTTP(w, req.WithContext(ctx))
+
+			case strings.EqualFold(scheme, "ApiKey") && raw != "" && scope != "":
+				key, err := keys.VerifyAPIKey(req.Context(), raw)
+				if err != nil {
+					unauthorized(w, err)
+					return
+				}
+
+				if !slices.Contains(key.Scopes, scope) {
+					response.Error(w, apperr.Forbidden("insufficient_scope", "api key is not allowed to access "+scope))
+					return
+				}
+
+				ctx := WithAPIKey(req.Context(), key.Id, key.Role)
+				next.ServeHTTP(w, req.WithContext(ctx))
+
+			case optional && req.Header.Get("Authorization") == "":
+				next.ServeHTTP(w, req)
+
+			default:
+				unauthorized(w, apperr.Unauthorized("missing_token", "a bearer access token is required"))
+			}
+		})
+	}
+}
+
+func WithUser(ctx context.Context, userId, role string) context.Context {
+	ctx = context.WithValue(ctx, userIDKey{}, userId)
+	ctx = context.WithValue(ctx, roleKey{}, role)
+	return logging.WithAttrs(ctx, slog.String("user_id", userId))
+}
+
+func WithAPIKey(ctx context.Context, keyId, role string) context.Context {
+	ctx = context.WithValue(ctx, apiKeyIDKey{}, keyId)
+	ctx = context.WithValue(ctx, roleKey{}, role)
+	return logging.WithAttrs(ctx, slog.String("api_key_id", keyId))
+}
+
+// UserIDFromContext returns the authenticated caller, or an empty string on
+// routes that don't require authentication and for api keys.
+func UserIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(userIDKey{}).(string)
+	return id
+}
+
+func APIKeyIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(apiKeyIDKey{}).(string)
+	return id
+}
+
+func RoleFromContext(ctx context.Context) string {
+	role, _ := ctx.Value(roleKey{}).(string)
+	return role
+}
+
+func unauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api", ApiKey realm="api"`)
+	response.Error(w, err)
+}
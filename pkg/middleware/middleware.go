@@ -0,0 +1,47 @@
+package middleware
+
+import "net/http"
+
+type Middleware func(next http.Handler) http.Handler
+
+// Chain wraps handler so the first middleware is the outermost one and sees
+// the request first.
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+	err    error
+}
+
+// RecordError keeps the cause of an internal error answered through
+// response.Error so the access log can report it.
+func (recorder *responseRecorder) RecordError(err error) {
+	recorder.err = err
+}
+
+func (recorder *responseRecorder) WriteHeader(status int) {
+	if recorder.status == 0 {
+		recorder.status = status
+	}
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *responseRecorder) Write(body []byte) (int, error) {
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	n, err := recorder.ResponseWriter.Write(body)
+	recorder.bytes += n
+	return n, err
+}
+
+func (recorder *responseRecorder) Unwrap() http.ResponseWriter {
+	return recorder.ResponseWriter
+}
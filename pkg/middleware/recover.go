@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"github.com/zchelalo/rest-api-go/pkg/response"
+)
+
+func Recover(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			recorder := &responseRecorder{ResponseWriter: w}
+
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				logger.ErrorContext(req.Context(), "panic recovered", "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+
+				// too late to change the response once the handler started writing it
+				if recorder.status == 0 {
+					response.Error(w, apperr.Internal(fmt.Errorf("panic: %v", recovered)))
+				}
+			}()
+
+			next.ServeHTTP(recorder, req)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/zchelalo/rest-api-go/pkg/logging"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// incoming ids are only trusted when they are short and printable so clients
+// can't inject arbitrary content into the logs
+var requestIDRegexp = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			id := req.Header.Get(RequestIDHeader)
+			if !requestIDRegexp.MatchString(id) {
+				id = uuid.New().String()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+			ctx := context.WithValue(req.Context(), requestIDKey{}, id)
+			ctx = logging.WithAttrs(ctx, slog.String("request_id", id))
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,355 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockKey is the postgres advisory lock taken while applying migrations so
+// several instances starting at once don't run the same migration twice.
+const lockKey = 724031
+
+var (
+	ErrSchemaBehind = errors.New("database schema is behind")
+	ErrNoMigrations = errors.New("no migrations to roll back")
+
+	fileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+)
+
+type (
+	Migration struct {
+		Version int64
+		Name    string
+		Up      string
+		Down    string
+	}
+
+	Status struct {
+		Version   int64
+		Name      string
+		AppliedAt *time.Time
+	}
+
+	// State is the applied version along with the embedded migrations still
+	// pending.
+	State struct {
+		Version int64
+		Pending []string
+	}
+
+	schemaMigration struct {
+		Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+		Name      string    `gorm:"type:varchar(255);not null"`
+		AppliedAt time.Time `gorm:"not null"`
+	}
+
+	Migrator struct {
+		log        *slog.Logger
+		db         *gorm.DB
+		migrations []Migration
+	}
+)
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+func New(log *slog.Logger, db *gorm.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		log:        log,
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+func (migrator *Migrator) Up() error {
+	if err := migrator.ensureTable(); err != nil {
+		return err
+	}
+
+	for _, migration := range migrator.migrations {
+		err := migrator.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error; err != nil {
+				return err
+			}
+
+			var count int64
+			if err := tx.Model(&schemaMigration{}).Where("version = ?", migration.Version).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return err
+			}
+
+			migrator.log.Info("migration applied", "version", migration.Version, "name", migration.Name)
+			return tx.Create(&schemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Down rolls back the most recently applied migration.
+func (migrator *Migrator) Down() error {
+	if err := migrator.ensureTable(); err != nil {
+		return err
+	}
+
+	return migrator.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error; err != nil {
+			return err
+		}
+
+		var applied []schemaMigration
+		if err := tx.Order("version desc").Limit(1).Find(&applied).Error; err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			return ErrNoMigrations
+		}
+
+		migration, ok := migrator.find(applied[0].Version)
+		if !ok {
+			return fmt.Errorf("migration %04d is applied but missing from the binary", applied[0].Version)
+		}
+
+		if err := tx.Exec(migration.Down).Error; err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		migrator.log.Info("migration rolled back", "version", migration.Version, "name", migration.Name)
+		return tx.Delete(&schemaMigration{}, "version = ?", migration.Version).Error
+	})
+}
+
+func (migrator *Migrator) Status() ([]Status, error) {
+	applied, err := migrator.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrator.migrations))
+	for _, migration := range migrator.migrations {
+		status := Status{
+			Version: migration.Version,
+			Name:    migration.Name,
+		}
+		if row, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Version returns the highest applied migration version, or 0 when none has
+// been applied yet.
+func (migrator *Migrator) Version() (int64, error) {
+	applied, err := migrator.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	var version int64
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// Check returns ErrSchemaBehind when any embedded migration hasn't been
+// applied to the database.
+func (migrator *Migrator) Check() error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, fmt.Sprintf("%04d_%s", status.Version, status.Name))
+		}
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("%w, pending migrations: %s", ErrSchemaBehind, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// State reads the applied versions with a single query and, unlike Status and
+// Check, never creates the schema_migrations table, so it suits probes that run
+// often.
+func (migrator *Migrator) State(ctx context.Context) (State, error) {
+	var versions []int64
+	if err := migrator.db.WithContext(ctx).Model(&schemaMigration{}).Pluck("version", &versions).Error; err != nil {
+		return State{}, err
+	}
+
+	applied := make(map[int64]bool, len(versions))
+	var state State
+	for _, version := range versions {
+		applied[version] = true
+		if version > state.Version {
+			state.Version = version
+		}
+	}
+
+	for _, migration := range migrator.migrations {
+		if !applied[migration.Version] {
+			state.Pending = append(state.Pending, fmt.Sprintf("%04d_%s", migration.Version, migration.Name))
+		}
+	}
+
+	return state, nil
+}
+
+func (migrator *Migrator) ensureTable() error {
+	return migrator.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    bigint       NOT NULL PRIMARY KEY,
+		name       varchar(255) NOT NULL,
+		applied_at timestamptz  NOT NULL
+	)`).Error
+}
+
+func (migrator *Migrator) applied() (map[int64]schemaMigration, error) {
+	if err := migrator.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	var rows []schemaMigration
+	if err := migrator.db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]schemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func (migrator *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range migrator.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// Create writes a new up/down migration pair into dir, numbered after the
+// highest version already present there.
+func Create(dir, name string) ([]string, error) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), "_"))
+	if !regexp.MustCompile(`^\w+$`).MatchString(name) {
+		return nil, fmt.Errorf("invalid migration name %q", name)
+	}
+
+	migrations, err := load(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	var files []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", version, name, direction))
+		content := fmt.Sprintf("-- %04d_%s (%s)\n", version, name, direction)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+
+	return files, nil
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		matches := fileRegexp.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != matches[2] {
+			return nil, fmt.Errorf("migration %04d has conflicting names %s and %s", version, migration.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
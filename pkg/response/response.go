@@ -0,0 +1,106 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+	"github.com/zchelalo/rest-api-go/pkg/meta"
+)
+
+type status string
+
+const (
+	statusSuccess status = "success"
+	statusError   status = "error"
+)
+
+// ErrorRecorder is implemented by response writers that want the cause of
+// internal errors, which never reaches the client, e.g. to log it.
+type ErrorRecorder interface {
+	RecordError(err error)
+}
+
+type Response struct {
+	Status status              `json:"status"`
+	Data   interface{}         `json:"data,omitempty"`
+	Error  string              `json:"error,omitempty"`
+	Code   string              `json:"code,omitempty"`
+	Errors []apperr.FieldError `json:"errors,omitempty"`
+	Meta   *meta.Meta          `json:"meta,omitempty"`
+}
+
+func OK(w http.ResponseWriter, data interface{}, meta *meta.Meta) {
+	write(w, http.StatusOK, &Response{
+		Status: statusSuccess,
+		Data:   data,
+		Meta:   meta,
+	})
+}
+
+func Created(w http.ResponseWriter, data interface{}) {
+	write(w, http.StatusCreated, &Response{
+		Status: statusSuccess,
+		Data:   data,
+	})
+}
+
+// Accepted answers 202 for work that continues after the response, like
+// sending an email.
+func Accepted(w http.ResponseWriter, data interface{}) {
+	write(w, http.StatusAccepted, &Response{
+		Status: statusSuccess,
+		Data:   data,
+	})
+}
+
+// Unavailable answers 503 while still reporting data, e.g. which dependency
+// made a readiness check fail.
+func Unavailable(w http.ResponseWriter, data interface{}) {
+	write(w, http.StatusServiceUnavailable, &Response{
+		Status: statusError,
+		Data:   data,
+		Error:  "service unavailable",
+	})
+}
+
+func Error(w http.ResponseWriter, err error) {
+	appErr := apperr.From(err)
+
+	if appErr.Kind == apperr.KindInternal {
+		cause := appErr.Err
+		if cause == nil {
+			cause = appErr
+		}
+		recordError(w, cause)
+	}
+
+	write(w, appErr.StatusCode(), &Response{
+		Status: statusError,
+		Data:   appErr.Details,
+		Error:  appErr.Message,
+		Code:   appErr.Code,
+		Errors: appErr.Fields,
+	})
+}
+
+// recordError hands err to every ErrorRecorder wrapped around w.
+func recordError(w http.ResponseWriter, err error) {
+	for w != nil {
+		if recorder, ok := w.(ErrorRecorder); ok {
+			recorder.RecordError(err)
+		}
+
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+func write(w http.ResponseWriter, statusCode int, response *Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,77 @@
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const secret = "ssssssssssssssssssssssssssssssss"
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, claims Claims) string {
+	t.Helper()
+	signed, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func claims(issuer, subject string, expiresAt time.Time) Claims {
+	return Claims{
+		Role: "student",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+}
+
+func TestManagerParse(t *testing.T) {
+	manager := NewManager(secret, "rest-api-go", time.Minute)
+	later := time.Now().Add(time.Minute)
+
+	issued, _, err := manager.Issue("user", "student")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		raw   string
+		valid bool
+	}{
+		{"issued", issued, true},
+		{"hs256", sign(t, jwt.SigningMethodHS256, []byte(secret), claims("rest-api-go", "user", later)), true},
+		{"other algorithm", sign(t, jwt.SigningMethodHS512, []byte(secret), claims("rest-api-go", "user", later)), false},
+		{"none algorithm", sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, claims("rest-api-go", "user", later)), false},
+		{"other secret", sign(t, jwt.SigningMethodHS256, []byte(strings.Repeat("x", 32)), claims("rest-api-go", "user", later)), false},
+		{"other issuer", sign(t, jwt.SigningMethodHS256, []byte(secret), claims("someone-else", "user", later)), false},
+		{"expired", sign(t, jwt.SigningMethodHS256, []byte(secret), claims("rest-api-go", "user", time.Now().Add(-time.Minute))), false},
+		{"without expiry", sign(t, jwt.SigningMethodHS256, []byte(secret), Claims{RegisteredClaims: jwt.RegisteredClaims{Issuer: "rest-api-go", Subject: "user"}}), false},
+		{"without subject", sign(t, jwt.SigningMethodHS256, []byte(secret), claims("rest-api-go", "", later)), false},
+		{"malformed", "not.a.token", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed, err := manager.Parse(test.raw)
+			if !test.valid {
+				if err != ErrInvalidToken {
+					t.Errorf("Parse() = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Parse() = %v", err)
+			}
+			if parsed.Subject != "user" || parsed.Role != "student" {
+				t.Errorf("Parse() = %+v, want the user's claims", parsed)
+			}
+		})
+	}
+}
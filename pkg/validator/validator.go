@@ -0,0 +1,135 @@
+package validator
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+)
+
+// Struct validates the exported fields of v against their `validate` tags and
+// returns every violation at once as an apperr validation error.
+//
+// Rules are comma separated: required, email, phone, uuid, date, datetime,
+// min=N, max=N and oneof=a b c. Nil pointers are skipped so partial update
+// requests only validate the fields that were sent, and format rules ignore
+// empty strings unless required is also present. Field names are taken from
+// the json tag.
+func Struct(v interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields apperr.FieldErrors
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if fieldValue.Kind() == reflect.Ptr {
+			if fieldValue.IsNil() {
+				continue
+			}
+			fieldValue = fieldValue.Elem()
+		}
+
+		name := jsonName(field)
+		for _, rule := range strings.Split(tag, ",") {
+			ruleName, param, _ := strings.Cut(rule, "=")
+			if ok, message := check(ruleName, param, fieldValue); !ok {
+				fields.Add(name, ruleName, message)
+				if ruleName == "required" {
+					break
+				}
+			}
+		}
+	}
+
+	return fields.Err()
+}
+
+var phoneRegexp = regexp.MustCompile(`^\+?[0-9][0-9 ()-]{5,28}[0-9]$`)
+
+func check(rule, param string, value reflect.Value) (bool, string) {
+	if rule == "required" {
+		return !value.IsZero() && strings.TrimSpace(fmt.Sprint(value.Interface())) != "", "Is required"
+	}
+
+	if value.Kind() == reflect.String && value.String() == "" {
+		return true, ""
+	}
+
+	switch rule {
+	case "email":
+		// surrounding spaces are left for the services to normalize away
+		trimmed := strings.TrimSpace(value.String())
+		address, err := mail.ParseAddress(trimmed)
+		return err == nil && address.Address == trimmed, "Must be a valid email address"
+	case "phone":
+		return phoneRegexp.MatchString(value.String()), "Must be a valid phone number"
+	case "uuid":
+		_, err := uuid.Parse(value.String())
+		return err == nil && len(value.String()) == 36, "Must be a valid UUID"
+	case "date":
+		_, err := time.Parse("2006-01-02", value.String())
+		return err == nil, "Must use the 2006-01-02 format"
+	case "datetime":
+		_, err := time.Parse(time.RFC3339, value.String())
+		return err == nil, "Must be an RFC 3339 timestamp"
+	case "oneof":
+		for _, option := range strings.Fields(param) {
+			if fmt.Sprint(value.Interface()) == option {
+				return true, ""
+			}
+		}
+		return false, fmt.Sprintf("Must be one of: %s", strings.Join(strings.Fields(param), ", "))
+	case "min", "max":
+		limit, err := strconv.Atoi(param)
+		if err != nil {
+			panic(fmt.Sprintf("validator: invalid %s parameter %q", rule, param))
+		}
+		return checkBound(rule, limit, value)
+	}
+
+	panic(fmt.Sprintf("validator: unknown rule %q", rule))
+}
+
+func checkBound(rule string, limit int, value reflect.Value) (bool, string) {
+	var size int
+	var unit string
+	switch value.Kind() {
+	case reflect.String:
+		size = utf8.RuneCountInString(value.String())
+		unit = " characters"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = int(value.Int())
+	default:
+		size = value.Len()
+		unit = " items"
+	}
+
+	if rule == "min" {
+		return size >= limit, fmt.Sprintf("Must be at least %d%s", limit, unit)
+	}
+	return size <= limit, fmt.Sprintf("Must be at most %d%s", limit, unit)
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,103 @@
+package validator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/zchelalo/rest-api-go/pkg/apperr"
+)
+
+type request struct {
+	Name     string   `json:"name" validate:"required,max=5"`
+	Email    string   `json:"email" validate:"email"`
+	Phone    *string  `json:"phone" validate:"required,phone"`
+	Id       string   `json:"id" validate:"uuid"`
+	Date     string   `json:"date" validate:"date"`
+	Datetime string   `json:"datetime" validate:"datetime"`
+	Role     string   `json:"role" validate:"oneof=admin student"`
+	Count    int      `json:"count" validate:"min=1"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	Ignored  string   `json:"-" validate:"max=1"`
+}
+
+func valid() request {
+	return request{
+		Name:     "Lalo",
+		Email:    "lalo@example.com",
+		Id:       "3a113f84-c474-46ba-ba16-e77eedacb99a",
+		Date:     "2024-01-31",
+		Datetime: "2024-01-31T10:00:00Z",
+		Role:     "admin",
+		Count:    1,
+	}
+}
+
+func ptr(value string) *string {
+	return &value
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(req *request)
+		field  string
+		code   string
+	}{
+		{"valid", func(req *request) {}, "", ""},
+		{"required", func(req *request) { req.Name = "  " }, "name", "required"},
+		{"max characters", func(req *request) { req.Name = "Eduardo" }, "name", "max"},
+		{"max counts runes", func(req *request) { req.Name = "ñañañ" }, "", ""},
+		{"email", func(req *request) { req.Email = "Lalo <lalo@example.com>" }, "email", "email"},
+		{"email with surrounding spaces", func(req *request) { req.Email = " lalo@example.com " }, "", ""},
+		{"empty email is skipped", func(req *request) { req.Email = "" }, "", ""},
+		{"nil pointer is skipped", func(req *request) { req.Phone = nil }, "", ""},
+		{"required pointer", func(req *request) { req.Phone = ptr("") }, "phone", "required"},
+		{"phone", func(req *request) { req.Phone = ptr("12ab") }, "phone", "phone"},
+		{"valid phone", func(req *request) { req.Phone = ptr("+52 (662) 123-4567") }, "", ""},
+		{"uuid", func(req *request) { req.Id = "3a113f84c474" }, "id", "uuid"},
+		{"date", func(req *request) { req.Date = "31/01/2024" }, "date", "date"},
+		{"datetime", func(req *request) { req.Datetime = "2024-01-31 10:00" }, "datetime", "datetime"},
+		{"oneof", func(req *request) { req.Role = "root" }, "role", "oneof"},
+		{"min number", func(req *request) { req.Count = 0 }, "count", "min"},
+		{"max items", func(req *request) { req.Tags = []string{"a", "b", "c"} }, "tags", "max"},
+		{"json name falls back to field", func(req *request) { req.Ignored = "ab" }, "Ignored", "max"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := valid()
+			test.modify(&req)
+
+			err := Struct(req)
+			if test.field == "" {
+				if err != nil {
+					t.Fatalf("Struct() = %v, want nil", err)
+				}
+				return
+			}
+
+			var appErr *apperr.Error
+			if !errors.As(err, &appErr) || appErr.Kind != apperr.KindValidation {
+				t.Fatalf("Struct() = %v, want a validation error", err)
+			}
+			if len(appErr.Fields) != 1 || appErr.Fields[0].Field != test.field || appErr.Fields[0].Code != test.code {
+				t.Errorf("Struct() fields = %+v, want %s on %s", appErr.Fields, test.code, test.field)
+			}
+		})
+	}
+}
+
+func TestStructReportsEveryField(t *testing.T) {
+	req := valid()
+	req.Name = ""
+	req.Email = "nope"
+	req.Role = "root"
+
+	var appErr *apperr.Error
+	if err := Struct(&req); !errors.As(err, &appErr) {
+		t.Fatalf("Struct() = %v, want a validation error", err)
+	}
+	if len(appErr.Fields) != 3 {
+		t.Errorf("Struct() reported %d fields, want 3: %+v", len(appErr.Fields), appErr.Fields)
+	}
+}